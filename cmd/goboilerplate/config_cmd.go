@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/moukoublen/goboilerplate/internal/config"
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+)
+
+const configUsage = `usage: goboilerplate config <command> [flags]
+
+commands:
+  explain   print every effective config key with its value, source layer and the layers it overrode
+  docs      print the reference of every config key (--format markdown, json-schema or yaml for a sample config.yaml)
+`
+
+// runConfigCommand implements the `goboilerplate config ...` subcommands and returns the exit code.
+func runConfigCommand(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		_, _ = fmt.Fprint(stderr, configUsage)
+		return 2
+	}
+
+	// keep config loading logs out of the command output.
+	ctx = zlog.SetInContext(ctx, slog.New(zlog.NOOPLogHandler{}))
+
+	switch args[0] {
+	case "explain":
+		opts, err := parseConfigFlags("config "+args[0], args[1:], stderr)
+		if err != nil {
+			return 2
+		}
+
+		var cnf appConfig
+		snap, err := config.Load(ctx, opts, &cnf)
+		if werr := config.WriteExplain(stdout, snap.Explain()); werr != nil {
+			_, _ = fmt.Fprintln(stderr, werr.Error())
+			return 1
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, err.Error())
+			return 1
+		}
+
+		return 0
+
+	case "docs":
+		fs := flag.NewFlagSet("config docs", flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		format := fs.String("format", "markdown", "output format: markdown, json-schema or yaml")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+
+		var err error
+		switch *format {
+		case "markdown":
+			err = config.WriteMarkdown(stdout, config.Docs(appConfig{}, envVarPrefix))
+		case "json-schema":
+			err = config.WriteJSONSchema(stdout, appConfig{})
+		case "yaml":
+			err = config.WriteSampleYAML(stdout, appConfig{})
+		default:
+			_, _ = fmt.Fprintf(stderr, "unknown format %q\n", *format)
+			return 2
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, err.Error())
+			return 1
+		}
+
+		return 0
+
+	default:
+		_, _ = fmt.Fprintf(stderr, "unknown config command %q\n%s", args[0], configUsage)
+		return 2
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+
+	"github.com/moukoublen/goboilerplate/internal/config"
+)
+
+// stringsFlag is a flag that can be repeated.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// parseConfigFlags parses the config selection flags (and the flags registered by extra) from args and returns the config options.
+func parseConfigFlags(name string, args []string, output io.Writer, extra ...func(fs *flag.FlagSet)) (config.Options, error) {
+	opts := config.Options{
+		EnvVarPrefix: envVarPrefix,
+	}
+
+	var files stringsFlag
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Var(&files, "config", "config file (yaml, toml or json), can be repeated. Overrides "+envVarPrefix+"CONFIG_FILE.")
+	fs.StringVar(&opts.Dir, "config-dir", "", "conf.d style directory with config files that are merged in lexical order. Overrides "+envVarPrefix+"CONFIG_DIR.")
+	fs.StringVar(&opts.Profile, "profile", "", "config profile, e.g. prod loads config.prod.yaml on top of config.yaml. Overrides "+envVarPrefix+"PROFILE.")
+	fs.StringVar(&opts.DotEnvFile, "env-file", "", "the .env file (default .env).")
+	fs.Func("config-strict", "how unknown config keys are treated: off, warn or error (default error for prod/production profiles, warn otherwise). Overrides "+envVarPrefix+"CONFIG_STRICT.", func(s string) error {
+		opts.Strict = config.StrictMode(s)
+		return nil
+	})
+	for _, e := range extra {
+		e(fs)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	opts.Files = files
+
+	return opts, nil
+}
@@ -2,10 +2,15 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"crypto/tls"
+	"errors"
+	"expvar"
+	"flag"
 	"log/slog"
-	"maps"
+	"net"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/ifnotnil/daemon"
 	"github.com/moukoublen/goboilerplate/internal/config"
@@ -13,60 +18,160 @@ import (
 	"github.com/moukoublen/goboilerplate/internal/zlog"
 )
 
-func defaultConfigs() map[string]any {
-	defaults := map[string]any{
-		"shutdown_timeout": "4s",
-	}
+type appConfig struct {
+	ShutdownTimeout time.Duration `koanf:"shutdown_timeout" default:"4s" validate:"gt=0" desc:"The grace period of the graceful shutdown."`
+	HTTP            zhttp.Config  `koanf:"http"`
+	Log             zlog.Config   `koanf:"log"`
+}
 
-	gather := []map[string]any{
-		zhttp.DefaultConfigValues(),
-		zlog.DefaultConfigValues(),
-	}
+const envVarPrefix = "APP_"
 
-	for _, g := range gather {
-		maps.Copy(defaults, g)
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(context.Background(), os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "openapi" {
+		os.Exit(runOpenAPICommand(context.Background(), os.Args[2:], os.Stdout, os.Stderr))
 	}
 
-	return defaults
-}
+	cnfOpts, err := parseConfigFlags(os.Args[0], os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		os.Exit(2)
+	}
 
-func main() {
 	// pre-init slog with default config
 	logger := zlog.InitSLog(zlog.Config{LogType: zlog.LogTypeText, Level: slog.LevelInfo})
 	logger.Info("starting up...")
 
-	cnf, err := config.Load(context.Background(), "APP_", defaultConfigs())
+	cnfWatcher, err := config.NewWatcher[appConfig](context.Background(), cnfOpts)
 	if err != nil {
 		logger.Error("error during config init", zlog.Error(err))
 		os.Exit(1)
 	}
+	cnf := cnfWatcher.Current()
+
+	logger = zlog.InitSLog(cnf.Log)
+	httpConf := cnf.HTTP
 
-	logger = zlog.InitSLog(zlog.ParseConfig(cnf))
+	// listening sockets are opened or, if inherited (systemd socket activation / graceful restart), taken over.
+	listeners, err := zhttp.NewListeners()
+	if err != nil {
+		logger.Error("error during inherited listeners init", zlog.Error(err))
+		os.Exit(1)
+	}
+	addrs := map[string]zhttp.Address{zhttp.ListenerPublic: httpConf.Address()}
+	if httpConf.Admin.Enabled {
+		addrs[zhttp.ListenerAdmin] = httpConf.Admin.Address()
+	}
+	if httpConf.Metrics.Enabled {
+		addrs[zhttp.ListenerMetrics] = httpConf.Metrics.Address()
+	}
+	lns := map[string]net.Listener{}
+	for name, addr := range addrs {
+		ln, err := listeners.Listen(name, addr)
+		if err != nil {
+			logger.Error("error during http listen", slog.String("listener", name), slog.String("address", addr.String()), zlog.Error(err))
+			os.Exit(1)
+		}
+		lns[name] = ln
+	}
+	var h3Conn net.PacketConn
+	if httpConf.HTTP3.Enabled {
+		h3Addr, err := httpConf.HTTP3Address()
+		if err == nil {
+			h3Conn, err = listeners.ListenPacket(zhttp.ListenerHTTP3, h3Addr)
+		}
+		if err != nil {
+			logger.Error("error during http3 listen", zlog.Error(err))
+			os.Exit(1)
+		}
+	}
 
 	dmn := daemon.Start(
 		context.Background(),
 		daemon.WithLogger(logger),
-		daemon.WithShutdownGraceDuration(cnf.Duration("shutdown_timeout")),
+		daemon.WithShutdownGraceDuration(cnf.ShutdownTimeout),
 	)
 
-	httpConf := zhttp.ParseConfig(cnf)
-	router := zhttp.NewDefaultRouter(dmn.CTX(), httpConf, logger)
+	// live reload of config files. The rest of the keys are applied on restart (and logged as such on change).
+	cnfWatcher.Subscribe("log.level", func(_ context.Context, c *appConfig) {
+		zlog.SetLogLevel(c.Log.Level)
+	})
+	if err := cnfWatcher.Start(dmn.CTX()); err != nil {
+		logger.Warn("config files watcher could not be started", zlog.Error(err))
+	}
+
+	var tlsConf *tls.Config
+	if httpConf.TLS.Enabled {
+		tlsConf, err = zhttp.NewTLSConfig(dmn.CTX(), httpConf.TLS)
+		if err != nil {
+			logger.Error("error during tls init", zlog.Error(err))
+			os.Exit(1)
+		}
+	}
+
+	auths, err := zhttp.NewAuthenticators(httpConf.Auth)
+	if err != nil {
+		logger.Error("error during auth init", zlog.Error(err))
+		os.Exit(1)
+	}
 
 	// init services / application
-	server := zhttp.StartListenAndServe(
-		fmt.Sprintf("%s:%d", httpConf.IP, httpConf.Port),
-		router,
-		httpConf.ReadHeaderTimeout,
-		dmn.FatalErrorsChannel(),
-	)
-	logger.InfoContext(dmn.CTX(), "service started", slog.String("bind", fmt.Sprintf("%s:%d", httpConf.IP, httpConf.Port)))
+	servers := &zhttp.Servers{}
+	publicOpts := httpConf.ServerOptions()
+	publicOpts.TLSConfig = tlsConf
+	// the public and the admin servers share the concurrency limit, as they share the resources of the process.
+	var limiter *zhttp.ConcurrencyLimiter
+	if httpConf.ConcurrencyLimit.Enabled {
+		limiter = zhttp.NewConcurrencyLimiter(httpConf.ConcurrencyLimit)
+		expvar.Publish("concurrency_limiter", expvar.Func(func() any { return limiter.Stats() }))
+	}
+	var publicHandler http.Handler = zhttp.NewDefaultRouter(dmn.CTX(), httpConf, auths, limiter, logger)
+	if h3Conn != nil {
+		// HTTP/3 shares the router (and the middlewares) of the public server.
+		h3, err := servers.StartHTTP3(dmn.CTX(), zhttp.ListenerHTTP3, h3Conn, publicHandler, publicOpts, dmn.FatalErrorsChannel())
+		if err != nil {
+			logger.Error("error during http3 init", zlog.Error(err))
+			os.Exit(1)
+		}
+		publicHandler = zhttp.AltSvc(h3)(publicHandler)
+	}
+	servers.Start(dmn.CTX(), zhttp.ListenerPublic, lns[zhttp.ListenerPublic], publicHandler, publicOpts, dmn.FatalErrorsChannel())
+
+	if ln, found := lns[zhttp.ListenerAdmin]; found {
+		adminRouter := zhttp.NewAdminRouter(dmn.CTX(), httpConf, limiter)
+		if httpConf.DebugEndpoints {
+			adminRouter.Get("/debug/config", zhttp.ConfigExplainHandler(func() []config.Entry { return cnfWatcher.Snapshot().Explain() }))
+		}
+		servers.Start(dmn.CTX(), zhttp.ListenerAdmin, ln, adminRouter, httpConf.Admin.ServerOptions(), dmn.FatalErrorsChannel())
+	}
+
+	if ln, found := lns[zhttp.ListenerMetrics]; found {
+		servers.Start(dmn.CTX(), zhttp.ListenerMetrics, ln, zhttp.NewMetricsRouter(dmn.CTX()), httpConf.Metrics.ServerOptions(), dmn.FatalErrorsChannel())
+	}
+
+	logger.InfoContext(dmn.CTX(), "service started")
+
+	// every listener is served; if this process was started by a graceful restart, the old one can now drain.
+	listeners.Close()
+	if err := listeners.Ready(); err != nil {
+		logger.WarnContext(dmn.CTX(), "error while notifying the parent process", zlog.Error(err))
+	}
+	if httpConf.GracefulRestart {
+		if err := listeners.RestartOnSignal(dmn.CTX()); err != nil {
+			logger.WarnContext(dmn.CTX(), "graceful restart is not supported", zlog.Error(err))
+		}
+	}
 
 	// set onShutdown for other components/services.
 	dmn.OnShutDown(
 		func(ctx context.Context) {
-			logger.InfoContext(ctx, "shuting down http server")
-			if err := server.Shutdown(ctx); err != nil {
-				logger.Warn("error during http server shutdown", zlog.Error(err))
+			logger.InfoContext(ctx, "shuting down http servers")
+			if err := servers.Shutdown(ctx); err != nil {
+				logger.Warn("error during http servers shutdown", zlog.Error(err))
 			}
 		},
 	)
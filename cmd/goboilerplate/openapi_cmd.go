@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/moukoublen/goboilerplate/internal/config"
+	"github.com/moukoublen/goboilerplate/internal/zhttp"
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+)
+
+// runOpenAPICommand implements `goboilerplate openapi`, which writes the OpenAPI document of the public routes
+// (as configured) to stdout or to the --output file, and returns the exit code.
+func runOpenAPICommand(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	var output string
+	opts, err := parseConfigFlags("openapi", args, stderr, func(fs *flag.FlagSet) {
+		fs.StringVar(&output, "output", "", "the file to write the OpenAPI document to (default stdout).")
+	})
+	if err != nil {
+		return 2
+	}
+
+	// keep config loading and router logs out of the command output.
+	logger := slog.New(zlog.NOOPLogHandler{})
+	ctx = zlog.SetInContext(ctx, logger)
+
+	var cnf appConfig
+	if _, err := config.Load(ctx, opts, &cnf); err != nil {
+		_, _ = fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+
+	// the security schemes are documented whether the authenticators are enabled or not; their files are not needed.
+	_, api := zhttp.NewDefaultAPI(ctx, cnf.HTTP, zhttp.Authenticators{}, nil, logger)
+	b, err := json.MarshalIndent(api.OpenAPI(), "", "  ")
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+	b = append(b, '\n')
+
+	if output == "" {
+		_, err = stdout.Write(b)
+	} else {
+		err = os.WriteFile(output, b, 0o644) //nolint:gosec // a spec file, meant to be committed.
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+
+	return 0
+}
@@ -3,52 +3,253 @@ package config
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io/fs"
+	"log/slog"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/knadh/koanf/parsers/dotenv"
-	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/confmap"
 	"github.com/knadh/koanf/providers/env"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/v2"
+	"github.com/moukoublen/goboilerplate/internal/validate"
 	"github.com/moukoublen/goboilerplate/internal/zlog"
 )
 
-func Load(ctx context.Context, envVarPrefix string, defaultConfigs map[string]any) (*koanf.Koanf, error) {
+const delim = "."
+
+// Layer sources. File layers are named after their path, e.g. file:config.yaml or dotenv:.env.
+const (
+	SourceDefaults = "defaults"
+	SourceEnv      = "env"
+)
+
+// Issue is a single invalid configuration key.
+type Issue struct {
+	Key    string // e.g. http.port
+	Source string // the layer that supplied the value, e.g. env
+	Err    error
+}
+
+func (i Issue) Error() string {
+	return fmt.Sprintf("%s (from %s): %s", i.Key, i.Source, i.Err.Error())
+}
+
+func (i Issue) Unwrap() error { return i.Err }
+
+// Error aggregates every invalid key found during Load.
+type Error struct {
+	Issues []Issue
+}
+
+func (e *Error) Error() string {
+	s := make([]string, 0, len(e.Issues))
+	for _, i := range e.Issues {
+		s = append(s, i.Error())
+	}
+
+	return "invalid configuration: " + strings.Join(s, "; ")
+}
+
+func (e *Error) Unwrap() []error {
+	errs := make([]error, 0, len(e.Issues))
+	for _, i := range e.Issues {
+		errs = append(errs, i)
+	}
+
+	return errs
+}
+
+type layer struct {
+	source string
+	k      *koanf.Koanf
+}
+
+type layers []layer
+
+// sourceOf returns the name of the last (highest priority) layer that contains key.
+func (l layers) sourceOf(key string) string {
+	for _, ly := range slices.Backward(l) {
+		if ly.k.Exists(key) {
+			return ly.source
+		}
+	}
+
+	return "unset"
+}
+
+// Snapshot is the outcome of Load: the merged configuration along with the layers it was built from.
+type Snapshot struct {
+	k       *koanf.Koanf
+	layers  layers
+	secrets map[string]struct{}
+}
+
+// Koanf returns the merged configuration tree.
+func (s *Snapshot) Koanf() *koanf.Koanf {
+	return s.k
+}
+
+// Source returns the name of the layer that supplied the effective value of key.
+func (s *Snapshot) Source(key string) string {
+	return s.layers.sourceOf(key)
+}
+
+// Load builds the layered configuration (defaults, config files, env vars, .env file), resolves
+// the secret references (see Secret), unmarshals it into target (a pointer to struct, using `koanf` struct tags) and validates it
+// using the `validate` struct tags. Every invalid key is reported in a single *Error.
+// The returned snapshot is non nil even when the configuration is invalid, so it can be inspected.
+func Load(ctx context.Context, opts Options, target any) (*Snapshot, error) {
+	opts = opts.resolved()
+	if err := opts.Strict.validate(); err != nil {
+		return &Snapshot{k: koanf.New(delim), secrets: map[string]struct{}{}}, err
+	}
+
+	defaults := defaultsOf(opts, target)
+	k, lrs, err := loadLayers(ctx, opts, defaults, target)
+	snap := &Snapshot{k: k, layers: lrs, secrets: secretKeys(target)}
+	if err != nil {
+		return snap, err
+	}
+
+	resolved, issues := resolveSecrets(k, lrs)
+	maps.Copy(snap.secrets, resolved)
+
+	issues = append(issues, checkUnknownKeys(ctx, opts, lrs, defaults, target)...)
+
+	err = unmarshal(k, lrs, target)
+	if len(issues) == 0 {
+		return snap, err
+	}
+
+	var unmarshalErr *Error
+	if errors.As(err, &unmarshalErr) {
+		issues = append(issues, unmarshalErr.Issues...)
+	}
+
+	return snap, &Error{Issues: issues}
+}
+
+// defaultsOf returns the `default` tags of target, overridden by opts.Defaults.
+func defaultsOf(opts Options, target any) map[string]any {
+	defaults := Defaults(target)
+	maps.Copy(defaults, opts.Defaults)
+
+	return defaults
+}
+
+func loadLayers(ctx context.Context, opts Options, defaults map[string]any, target any) (*koanf.Koanf, layers, error) {
 	logger := zlog.GetFromContext(ctx)
 
-	const delim = "."
 	k := koanf.New(delim)
+	var lrs layers
+
+	load := func(source string, p koanf.Provider, pa koanf.Parser) error {
+		lk := koanf.New(delim)
+		if err := lk.Load(p, pa); err != nil {
+			return err
+		}
+		lrs = append(lrs, layer{source: source, k: lk})
+
+		return k.Merge(lk)
+	}
 
 	// Load default values.
-	if err := k.Load(confmap.Provider(defaultConfigs, delim), nil); err != nil {
+	if err := load(SourceDefaults, confmap.Provider(defaults, delim), nil); err != nil {
 		logger.DebugContext(ctx, "error during config loading from defaults", zlog.Error(err))
 	}
 
-	// Load YAML config.
-	if err := k.Load(file.Provider("config.yaml"), yaml.Parser()); err != nil {
-		logger.DebugContext(ctx, "error during config loading from yaml file", zlog.Error(err))
-		if !errors.Is(err, fs.ErrNotExist) {
-			return k, err
-		}
+	// Load config files (yaml, toml, json).
+	files, err := opts.configFiles()
+	if err != nil {
+		return k, lrs, err
 	}
+	for _, f := range files {
+		parser, err := parserFor(f.path)
+		if err != nil {
+			return k, lrs, err
+		}
 
-	// Environment Variables layers
-	envVarsLevels := map[string]any{
-		"http": map[string]any{},
-		"log":  map[string]any{},
+		if err := load(fileSource(f.path), file.Provider(f.path), parser); err != nil {
+			logger.DebugContext(ctx, "error during config loading from file", slog.String("file", f.path), zlog.Error(err))
+			if !f.optional || !errors.Is(err, fs.ErrNotExist) {
+				return k, lrs, fmt.Errorf("config file %s: %w", f.path, err)
+			}
+		}
 	}
-	if err := k.Load(env.Provider(envVarPrefix, delim, buildEnvVarsNamesMapper(envVarsLevels, envVarPrefix)), nil); err != nil {
+
+	// Environment Variables layers. Env var names are mapped to the known keys (defaults and target fields).
+	keys, levelKeys := knownKeys(defaults, target)
+	mapper := newEnvKeyMapper(opts.EnvVarPrefix, keys, levelKeys)
+
+	if err := load(SourceEnv, env.Provider(opts.EnvVarPrefix, delim, mapper.Map), nil); err != nil {
 		logger.WarnContext(ctx, "error during config loading from env vars", zlog.Error(err))
 	}
 
 	// Load .env file
-	if err := k.Load(file.Provider(".env"), dotenv.ParserEnv(envVarPrefix, delim, buildEnvVarsNamesMapper(envVarsLevels, envVarPrefix))); err != nil {
+	if err := load(dotEnvSource(opts.DotEnvFile), file.Provider(opts.DotEnvFile), dotenv.ParserEnv(opts.EnvVarPrefix, delim, mapper.MapFrom(dotEnvSource(opts.DotEnvFile)))); err != nil {
 		logger.WarnContext(ctx, "error during config loading from dot env file", zlog.Error(err))
 		if !errors.Is(err, fs.ErrNotExist) {
-			return k, err
+			return k, lrs, err
 		}
 	}
 
-	return k, nil
+	if set := mapper.AmbiguousSet(); len(set) > 0 {
+		issues := make([]Issue, 0, len(set))
+		for _, a := range set {
+			issues = append(issues, Issue{
+				Key:    a.Name,
+				Source: a.Source,
+				Err:    fmt.Errorf("ambiguous env var, it matches keys %s", strings.Join(a.Keys, ", ")),
+			})
+		}
+
+		return k, lrs, &Error{Issues: issues}
+	}
+
+	return k, lrs, nil
+}
+
+// knownKeys returns the keys of the defaults and of the target fields, along with the keys of target map fields.
+func knownKeys(defaultConfigs map[string]any, target any) ([]string, []string) {
+	keys, mapKeys := targetKeys(target)
+
+	dk := koanf.New(delim)
+	_ = dk.Load(confmap.Provider(defaultConfigs, delim), nil)
+	keys = append(keys, dk.Keys()...)
+
+	return keys, mapKeys
+}
+
+// unmarshal decodes k into target and validates the result.
+func unmarshal(k *koanf.Koanf, lrs layers, target any) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config target must be a pointer to struct, got %T", target)
+	}
+
+	var issues []Issue
+	failed := map[string]struct{}{}
+
+	decodeStruct(k, "", rv.Elem(), func(key string, err error) {
+		failed[key] = struct{}{}
+		issues = append(issues, Issue{Key: key, Source: lrs.sourceOf(key), Err: err})
+	})
+
+	for _, fe := range validate.Struct(target, fieldKey, "", delim) {
+		if _, alreadyFailed := failed[fe.Path]; alreadyFailed {
+			continue
+		}
+		issues = append(issues, Issue{Key: fe.Path, Source: lrs.sourceOf(fe.Path), Err: errors.New(fe.Message)})
+	}
+
+	if len(issues) > 0 {
+		return &Error{Issues: issues}
+	}
+
+	return nil
 }
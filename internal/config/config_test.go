@@ -0,0 +1,129 @@
+package config
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/knadh/koanf/providers/confmap"
+	"github.com/knadh/koanf/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSection struct {
+	Port    int64         `koanf:"port"    validate:"min=1,max=65535"`
+	Type    string        `koanf:"type"    validate:"oneof=json text"`
+	Timeout time.Duration `koanf:"timeout" validate:"gt=0"`
+	Level   slog.Level    `koanf:"level"`
+	Names   []string      `koanf:"names"`
+	Enabled bool          `koanf:"enabled"`
+}
+
+type testConfig struct {
+	Name    string      `koanf:"name" validate:"required"`
+	Section testSection `koanf:"section"`
+}
+
+func buildLayers(t *testing.T, in []layerInput) (*koanf.Koanf, layers) {
+	t.Helper()
+
+	k := koanf.New(delim)
+	var lrs layers
+	for _, l := range in {
+		lk := koanf.New(delim)
+		require.NoError(t, lk.Load(confmap.Provider(l.values, delim), nil))
+		require.NoError(t, k.Merge(lk))
+		lrs = append(lrs, layer{source: l.source, k: lk})
+	}
+
+	return k, lrs
+}
+
+type layerInput struct {
+	source string
+	values map[string]any
+}
+
+func TestUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	defaults := layerInput{SourceDefaults, map[string]any{
+		"name":            "app",
+		"section.port":    8080,
+		"section.type":    "text",
+		"section.timeout": "5s",
+	}}
+
+	tests := map[string]struct {
+		layers         []layerInput
+		expected       testConfig
+		expectedIssues []Issue
+	}{
+		"defaults only": {
+			layers: []layerInput{defaults},
+			expected: testConfig{
+				Name:    "app",
+				Section: testSection{Port: 8080, Type: "text", Timeout: 5 * time.Second},
+			},
+		},
+		"env overrides as strings": {
+			layers: []layerInput{defaults, {SourceEnv, map[string]any{
+				"section.port":    "9090",
+				"section.level":   "debug",
+				"section.names":   "a, b",
+				"section.enabled": "true",
+			}}},
+			expected: testConfig{
+				Name: "app",
+				Section: testSection{
+					Port:    9090,
+					Type:    "text",
+					Timeout: 5 * time.Second,
+					Level:   slog.LevelDebug,
+					Names:   []string{"a", "b"},
+					Enabled: true,
+				},
+			},
+		},
+		"invalid values from several layers": {
+			layers: []layerInput{
+				defaults,
+				{fileSource(defaultConfigFile), map[string]any{"section.type": "xml", "section.timeout": "0s"}},
+				{SourceEnv, map[string]any{"section.port": "eighty", "name": ""}},
+			},
+			expectedIssues: []Issue{
+				{Key: "section.port", Source: SourceEnv},
+				{Key: "name", Source: SourceEnv},
+				{Key: "section.type", Source: fileSource(defaultConfigFile)},
+				{Key: "section.timeout", Source: fileSource(defaultConfigFile)},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			k, lrs := buildLayers(t, tc.layers)
+
+			var got testConfig
+			err := unmarshal(k, lrs, &got)
+
+			if len(tc.expectedIssues) == 0 {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, got)
+				return
+			}
+
+			var cErr *Error
+			require.True(t, errors.As(err, &cErr))
+			require.Len(t, cErr.Issues, len(tc.expectedIssues))
+			for i, expected := range tc.expectedIssues {
+				assert.Equal(t, expected.Key, cErr.Issues[i].Key)
+				assert.Equal(t, expected.Source, cErr.Issues[i].Source)
+				assert.Error(t, cErr.Issues[i].Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knadh/koanf/v2"
+)
+
+const structTag = "koanf"
+
+var errUnsupportedType = errors.New("unsupported type")
+
+//nolint:gochecknoglobals
+var (
+	durationType        = reflect.TypeFor[time.Duration]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// fieldKey returns the config key name of a struct field (the `koanf` tag or the lower-cased field name).
+func fieldKey(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get(structTag), ",")
+	if name == "" {
+		return strings.ToLower(sf.Name)
+	}
+
+	return name
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + delim + name
+}
+
+// decodeStruct sets every field of rv (a struct value) from the corresponding koanf key.
+// Fields whose key is missing are left untouched. Every conversion failure is reported to onErr
+// and the decoding continues with the rest of the fields.
+func decodeStruct(k *koanf.Koanf, prefix string, rv reflect.Value, onErr func(key string, err error)) {
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+		if isEmbedded(sf) {
+			decodeStruct(k, prefix, fv, onErr)
+			continue
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := fieldKey(sf)
+		if name == "-" {
+			continue
+		}
+
+		key := joinKey(prefix, name)
+
+		if isSection(fv.Type()) {
+			decodeStruct(k, key, fv, onErr)
+			continue
+		}
+
+		if !k.Exists(key) {
+			continue
+		}
+
+		if err := assign(fv, k.Get(key)); err != nil {
+			onErr(key, err)
+		}
+	}
+}
+
+// Defaulter can be implemented by config sections whose fields default to other values than their `default` tags,
+// typically because they embed a struct that other sections embed too. ConfigDefaults returns the defaults by field
+// key, written the way the `default` tags are; it is called on the zero value of the section.
+type Defaulter interface {
+	ConfigDefaults() map[string]string
+}
+
+// field is a field of a config section, along with its key and its default.
+type field struct {
+	reflect.StructField
+
+	name       string // the key of the field, relative to the section.
+	def        string
+	hasDefault bool
+}
+
+// sectionFields returns the fields of the section type rt, the fields of its embedded structs included, with their
+// defaults (see Defaulter).
+func sectionFields(rt reflect.Type) []field {
+	var defaults map[string]string
+	if d, isDefaulter := reflect.Zero(rt).Interface().(Defaulter); isDefaulter {
+		defaults = d.ConfigDefaults()
+	}
+
+	var fields []field
+	var collect func(rt reflect.Type)
+	collect = func(rt reflect.Type) {
+		for i := range rt.NumField() {
+			sf := rt.Field(i)
+			if isEmbedded(sf) {
+				collect(sf.Type)
+				continue
+			}
+			if !sf.IsExported() {
+				continue
+			}
+
+			f := field{StructField: sf, name: fieldKey(sf)}
+			if f.name == "-" {
+				continue
+			}
+			f.def, f.hasDefault = sf.Tag.Lookup(defaultTag)
+			if d, found := defaults[f.name]; found {
+				f.def, f.hasDefault = d, true
+			}
+			fields = append(fields, f)
+		}
+	}
+	collect(rt)
+
+	return fields
+}
+
+// walkFields calls fn for every value (non section) field of the struct type rt, recursively.
+func walkFields(rt reflect.Type, prefix string, fn func(key string, f field)) {
+	for _, f := range sectionFields(rt) {
+		key := joinKey(prefix, f.name)
+		if isSection(f.Type) {
+			walkFields(f.Type, key, fn)
+			continue
+		}
+
+		fn(key, f)
+	}
+}
+
+// isEmbedded reports whether sf is an embedded struct, whose fields are keys of the embedding section.
+func isEmbedded(sf reflect.StructField) bool {
+	return sf.Anonymous && isSection(sf.Type)
+}
+
+// isSection reports whether t is a nested config struct rather than a single value.
+func isSection(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+//nolint:cyclop,gocyclo
+func assign(fv reflect.Value, raw any) error {
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		s, err := asString(raw)
+		if err != nil {
+			return err
+		}
+
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)) //nolint:forcetypeassert
+	}
+
+	if fv.Type() == durationType {
+		s, isString := raw.(string)
+		if !isString {
+			return fmt.Errorf("expected a duration string (e.g. \"5s\"), got %T(%v)", raw, raw)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+
+		return nil
+	}
+
+	switch fv.Kind() { //nolint:exhaustive
+	case reflect.String:
+		s, err := asString(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetString(s)
+
+	case reflect.Bool:
+		switch b := raw.(type) {
+		case bool:
+			fv.SetBool(b)
+		case string:
+			parsed, err := strconv.ParseBool(strings.TrimSpace(b))
+			if err != nil {
+				return fmt.Errorf("cannot parse %q as bool", b)
+			}
+			fv.SetBool(parsed)
+		default:
+			return fmt.Errorf("expected bool, got %T(%v)", raw, raw)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := asInt64(raw)
+		if err != nil {
+			return err
+		}
+		if fv.OverflowInt(n) {
+			return fmt.Errorf("value %d overflows %s", n, fv.Type())
+		}
+		fv.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := asInt64(raw)
+		if err != nil {
+			return err
+		}
+		if n < 0 || fv.OverflowUint(uint64(n)) {
+			return fmt.Errorf("value %d overflows %s", n, fv.Type())
+		}
+		fv.SetUint(uint64(n))
+
+	case reflect.Float32, reflect.Float64:
+		f, err := asFloat64(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+
+	case reflect.Slice:
+		return assignSlice(fv, raw)
+
+	case reflect.Map:
+		return assignMap(fv, raw)
+
+	default:
+		return fmt.Errorf("%w %s", errUnsupportedType, fv.Type())
+	}
+
+	return nil
+}
+
+func assignSlice(fv reflect.Value, raw any) error {
+	var items []any
+	switch r := raw.(type) {
+	case []any:
+		items = r
+	case string: // env vars: comma separated values.
+		for p := range strings.SplitSeq(r, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				items = append(items, p)
+			}
+		}
+	default:
+		return fmt.Errorf("expected a list, got %T(%v)", raw, raw)
+	}
+
+	sl := reflect.MakeSlice(fv.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := assign(sl.Index(i), item); err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	fv.Set(sl)
+
+	return nil
+}
+
+func assignMap(fv reflect.Value, raw any) error {
+	m, isMap := raw.(map[string]any)
+	if !isMap {
+		return fmt.Errorf("expected a map, got %T(%v)", raw, raw)
+	}
+
+	if fv.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("%w %s", errUnsupportedType, fv.Type())
+	}
+
+	out := reflect.MakeMapWithSize(fv.Type(), len(m))
+	for k, v := range m {
+		ev := reflect.New(fv.Type().Elem()).Elem()
+		if err := assign(ev, v); err != nil {
+			return fmt.Errorf("item %s: %w", k, err)
+		}
+		out.SetMapIndex(reflect.ValueOf(k).Convert(fv.Type().Key()), ev)
+	}
+	fv.Set(out)
+
+	return nil
+}
+
+func asString(raw any) (string, error) {
+	switch r := raw.(type) {
+	case string:
+		return r, nil
+	case []byte:
+		return string(r), nil
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(r), nil
+	default:
+		return "", fmt.Errorf("expected a scalar value, got %T", raw)
+	}
+}
+
+func asInt64(raw any) (int64, error) {
+	switch r := raw.(type) {
+	case int:
+		return int64(r), nil
+	case int8:
+		return int64(r), nil
+	case int16:
+		return int64(r), nil
+	case int32:
+		return int64(r), nil
+	case int64:
+		return r, nil
+	case uint:
+		return int64(r), nil //nolint:gosec
+	case uint8:
+		return int64(r), nil
+	case uint16:
+		return int64(r), nil
+	case uint32:
+		return int64(r), nil
+	case uint64:
+		return int64(r), nil //nolint:gosec
+	case float64:
+		if r != float64(int64(r)) {
+			return 0, fmt.Errorf("expected an integer, got %v", r)
+		}
+		return int64(r), nil
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(r), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot parse %q as integer", r)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("expected an integer, got %T(%v)", raw, raw)
+	}
+}
+
+func asFloat64(raw any) (float64, error) {
+	switch r := raw.(type) {
+	case float64:
+		return r, nil
+	case float32:
+		return float64(r), nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(r), 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot parse %q as number", r)
+		}
+		return f, nil
+	default:
+		n, err := asInt64(raw)
+		if err != nil {
+			return 0, fmt.Errorf("expected a number, got %T(%v)", raw, raw)
+		}
+		return float64(n), nil
+	}
+}
@@ -0,0 +1,285 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Config keys are registered by the config structs themselves, using struct tags:
+//
+//	Port int64 `koanf:"port" default:"8888" validate:"min=0,max=65535" desc:"The port the http server listens to."`
+//
+// `default` values are written the way they would be written in an env var (lists are comma separated)
+// and form the lowest priority layer of Load. The fields of embedded structs are keys of the embedding section,
+// which may override their defaults (see Defaulter).
+
+const (
+	defaultTag = "default"
+	descTag    = "desc"
+)
+
+// KeyDoc documents a single registered config key.
+type KeyDoc struct {
+	Key         string `json:"key"`
+	EnvVar      string `json:"env_var"`
+	Type        string `json:"type"`
+	Default     string `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+	Rules       string `json:"rules,omitempty"`
+	Secret      bool   `json:"secret,omitempty"`
+}
+
+// Docs returns the documentation of every key registered by target (a config struct or a pointer to it).
+func Docs(target any, envVarPrefix string) []KeyDoc {
+	rt := structType(target)
+	if rt == nil {
+		return nil
+	}
+
+	var docs []KeyDoc
+	walkFields(rt, "", func(key string, sf field) {
+		docs = append(docs, KeyDoc{
+			Key:         key,
+			EnvVar:      envVarPrefix + envVarName(key),
+			Type:        typeName(sf.Type),
+			Default:     sf.def,
+			Description: sf.Tag.Get(descTag),
+			Rules:       sf.Tag.Get("validate"),
+			Secret:      sf.Type == reflect.TypeFor[Secret]() || sf.Tag.Get("secret") == "true",
+		})
+	})
+
+	return docs
+}
+
+// Defaults returns the values of the `default` tags of target, keyed by config key.
+func Defaults(target any) map[string]any {
+	out := map[string]any{}
+
+	rt := structType(target)
+	if rt == nil {
+		return out
+	}
+
+	walkFields(rt, "", func(key string, sf field) {
+		if sf.hasDefault {
+			out[key] = sf.def
+		}
+	})
+
+	return out
+}
+
+func structType(target any) reflect.Type {
+	rt := reflect.TypeOf(target)
+	for rt != nil && rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return nil
+	}
+
+	return rt
+}
+
+func typeName(t reflect.Type) string {
+	switch {
+	case t == reflect.TypeFor[Secret]():
+		return "secret"
+	case t == durationType:
+		return "duration"
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return "string"
+	}
+
+	switch t.Kind() { //nolint:exhaustive
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "uint"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Slice:
+		return "list of " + typeName(t.Elem())
+	case reflect.Map:
+		return "map of " + typeName(t.Elem())
+	default:
+		return t.Kind().String()
+	}
+}
+
+// WriteMarkdown renders docs as a markdown table.
+func WriteMarkdown(w io.Writer, docs []KeyDoc) error {
+	var b strings.Builder
+	b.WriteString("| Key | Env var | Type | Default | Description |\n")
+	b.WriteString("|-----|---------|------|---------|-------------|\n")
+	for _, d := range docs {
+		desc := d.Description
+		if d.Rules != "" {
+			desc = strings.TrimSpace(desc + " <br>Rules: `" + d.Rules + "`")
+		}
+		def := ""
+		if d.Default != "" {
+			def = "`" + d.Default + "`"
+		}
+		fmt.Fprintf(&b, "| `%s` | `%s` | %s | %s | %s |\n", d.Key, d.EnvVar, d.Type, def, strings.ReplaceAll(desc, "|", `\|`))
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// WriteJSONSchema renders the JSON Schema of the config files that target accepts.
+func WriteJSONSchema(w io.Writer, target any) error {
+	rt := structType(target)
+	if rt == nil {
+		return fmt.Errorf("config target must be a struct, got %T", target)
+	}
+
+	schema := structSchema(rt)
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(schema)
+}
+
+func structSchema(rt reflect.Type) map[string]any {
+	props := map[string]any{}
+	for _, sf := range sectionFields(rt) {
+		if isSection(sf.Type) {
+			props[sf.name] = structSchema(sf.Type)
+			continue
+		}
+
+		props[sf.name] = fieldSchema(sf)
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
+
+func fieldSchema(sf field) map[string]any {
+	s := typeSchema(sf.Type)
+	if d := sf.Tag.Get(descTag); d != "" {
+		s["description"] = d
+	}
+	if sf.hasDefault {
+		s["default"] = typedDefault(sf.Type, sf.def)
+	}
+
+	for rule := range strings.SplitSeq(sf.Tag.Get("validate"), ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch rule {
+		case "oneof":
+			s["enum"] = strings.Fields(param)
+		case "min", "max":
+			if s["type"] != "integer" && s["type"] != "number" {
+				continue
+			}
+			if n, err := strconv.ParseFloat(param, 64); err == nil {
+				s[map[string]string{"min": "minimum", "max": "maximum"}[rule]] = n
+			}
+		}
+	}
+
+	return s
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	switch {
+	case t == durationType:
+		return map[string]any{"type": "string", "pattern": `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$|^0$`}
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() { //nolint:exhaustive
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
+
+// typedDefault converts the default tag value d to the type t, in a json/yaml friendly form.
+func typedDefault(t reflect.Type, d string) any {
+	if t == durationType || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return d
+	}
+
+	v := reflect.New(t).Elem()
+	if err := assign(v, d); err != nil {
+		return d
+	}
+
+	return v.Interface()
+}
+
+// WriteSampleYAML renders a sample config.yaml with every key set to its default value.
+func WriteSampleYAML(w io.Writer, target any) error {
+	rt := structType(target)
+	if rt == nil {
+		return fmt.Errorf("config target must be a struct, got %T", target)
+	}
+
+	var b strings.Builder
+	writeSampleYAML(&b, rt, 0)
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+func writeSampleYAML(b *strings.Builder, rt reflect.Type, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, sf := range sectionFields(rt) {
+		if d := sf.Tag.Get(descTag); d != "" {
+			fmt.Fprintf(b, "%s# %s\n", indent, d)
+		}
+
+		if isSection(sf.Type) {
+			fmt.Fprintf(b, "%s%s:\n", indent, sf.name)
+			writeSampleYAML(b, sf.Type, depth+1)
+			continue
+		}
+
+		fmt.Fprintf(b, "%s%s: %s\n", indent, sf.name, yamlValue(sf))
+	}
+}
+
+func yamlValue(sf field) string {
+	d := sf.def
+	v := typedDefault(sf.Type, d)
+
+	if reflect.ValueOf(v).Kind() == reflect.String {
+		if d == "" || strings.ContainsAny(d, ":#{}[],&*!|>'\"%@`") || strings.TrimSpace(d) != d {
+			return strconv.Quote(d)
+		}
+		return d
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return strconv.Quote(d)
+	}
+
+	return string(b) // json scalars and lists are valid yaml.
+}
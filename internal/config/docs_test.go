@@ -0,0 +1,147 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type docsSection struct {
+	Port    int64         `koanf:"port"    default:"8080" validate:"min=1,max=65535" desc:"The port."`
+	Timeout time.Duration `koanf:"timeout" default:"5s"                              desc:"The timeout."`
+	Names   []string      `koanf:"names"   default:"a,b"`
+}
+
+type docsConfig struct {
+	Type     string      `koanf:"type"     default:"text" validate:"oneof=json text" desc:"The type."`
+	Password Secret      `koanf:"password"`
+	Section  docsSection `koanf:"section"`
+}
+
+func TestDocs(t *testing.T) {
+	t.Parallel()
+
+	expected := []KeyDoc{
+		{Key: "type", EnvVar: "APP_TYPE", Type: "string", Default: "text", Description: "The type.", Rules: "oneof=json text"},
+		{Key: "password", EnvVar: "APP_PASSWORD", Type: "secret", Secret: true},
+		{Key: "section.port", EnvVar: "APP_SECTION_PORT", Type: "int", Default: "8080", Description: "The port.", Rules: "min=1,max=65535"},
+		{Key: "section.timeout", EnvVar: "APP_SECTION_TIMEOUT", Type: "duration", Default: "5s", Description: "The timeout."},
+		{Key: "section.names", EnvVar: "APP_SECTION_NAMES", Type: "list of string", Default: "a,b"},
+	}
+	assert.Equal(t, expected, Docs(&docsConfig{}, "APP_"))
+
+	assert.Equal(t, map[string]any{
+		"type":            "text",
+		"section.port":    "8080",
+		"section.timeout": "5s",
+		"section.names":   "a,b",
+	}, Defaults(docsConfig{}))
+}
+
+func TestWriteSampleYAML(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteSampleYAML(buf, docsConfig{}))
+
+	expected := `# The type.
+type: text
+password: ""
+section:
+  # The port.
+  port: 8080
+  # The timeout.
+  timeout: 5s
+  names: ["a","b"]
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWriteJSONSchema(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteJSONSchema(buf, docsConfig{}))
+
+	schema := map[string]any{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &schema))
+
+	port := schema["properties"].(map[string]any)["section"].(map[string]any)["properties"].(map[string]any)["port"]
+	assert.Equal(t, map[string]any{
+		"type":        "integer",
+		"default":     float64(8080),
+		"description": "The port.",
+		"minimum":     float64(1),
+		"maximum":     float64(65535),
+	}, port)
+
+	typ := schema["properties"].(map[string]any)["type"]
+	assert.Equal(t, []any{"json", "text"}, typ.(map[string]any)["enum"])
+}
+
+type docsListener struct {
+	Port    int64         `koanf:"port"    validate:"min=1,max=65535" desc:"The port."`
+	Timeout time.Duration `koanf:"timeout" default:"5s"               desc:"The timeout."`
+}
+
+type docsServer struct {
+	Enabled bool `koanf:"enabled" default:"true"`
+	docsListener
+}
+
+func (docsServer) ConfigDefaults() map[string]string { return map[string]string{"port": "8080"} }
+
+type docsAdmin struct {
+	docsListener
+}
+
+func (docsAdmin) ConfigDefaults() map[string]string {
+	return map[string]string{"port": "8081", "timeout": "1m"}
+}
+
+type docsServersConfig struct {
+	Server docsServer `koanf:"server"`
+	Admin  docsAdmin  `koanf:"admin"`
+}
+
+func TestEmbeddedSections(t *testing.T) {
+	t.Parallel()
+
+	// the fields of embedded structs are keys of the embedding section, with the defaults of the section.
+	assert.Equal(t, map[string]any{
+		"server.enabled": "true",
+		"server.port":    "8080",
+		"server.timeout": "5s",
+		"admin.port":     "8081",
+		"admin.timeout":  "1m",
+	}, Defaults(docsServersConfig{}))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteSampleYAML(buf, docsServersConfig{}))
+	assert.Equal(t, `server:
+  enabled: true
+  # The port.
+  port: 8080
+  # The timeout.
+  timeout: 5s
+admin:
+  # The port.
+  port: 8081
+  # The timeout.
+  timeout: 1m
+`, buf.String())
+
+	opts := Options{EnvVarPrefix: "TEST_EMBEDDED_", DotEnvFile: filepath.Join(t.TempDir(), ".env"), Defaults: map[string]any{"admin.port": "9090"}}
+	var cnf docsServersConfig
+	_, err := Load(t.Context(), opts, &cnf)
+	require.NoError(t, err)
+	assert.Equal(t, docsServersConfig{
+		Server: docsServer{Enabled: true, docsListener: docsListener{Port: 8080, Timeout: 5 * time.Second}},
+		Admin:  docsAdmin{docsListener: docsListener{Port: 9090, Timeout: time.Minute}},
+	}, cnf)
+}
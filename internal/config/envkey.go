@@ -1,13 +1,146 @@
 package config
 
-import "strings"
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
 
-func buildEnvVarsNamesMapper(levels map[string]any, envVarPrefix string) func(string) string {
-	return func(s string) string {
-		s = strings.TrimPrefix(s, envVarPrefix)
-		s = strings.ToLower(s)
-		return buildKey(&strings.Builder{}, ".", "_", levels, strings.Split(s, "_"))
+// envKeyMapper maps env var names (e.g. APP_HTTP_READ_HEADER_TIMEOUT) to config keys (e.g. http.read_header_timeout).
+//
+// Known keys (defaults and config struct fields) are matched exactly, so underscores at any depth are resolved
+// correctly. Unknown names fall back to the levels tree that is derived from the known keys.
+// Names that match more than one known key are never guessed; they are ignored and recorded as ambiguous.
+type envKeyMapper struct {
+	prefix    string
+	exact     map[string]string   // env name (without prefix) -> key
+	ambiguous map[string][]string // env name (without prefix) -> candidate keys
+	levels    map[string]any
+
+	mu           sync.Mutex
+	ambiguousSet []ambiguousEnv // ambiguous env vars that are actually set.
+}
+
+// ambiguousEnv is an env var that is set but matches more than one known key.
+type ambiguousEnv struct {
+	Name   string   // the env var name, prefix included.
+	Source string   // the layer that set it, e.g. env or the .env file.
+	Keys   []string // the candidate keys.
+}
+
+// newEnvKeyMapper builds the mapper from the known keys. levelKeys are extra keys that hold
+// nested dynamic keys (e.g. map fields) and therefore act as levels.
+func newEnvKeyMapper(envVarPrefix string, keys, levelKeys []string) *envKeyMapper {
+	m := &envKeyMapper{
+		prefix:    envVarPrefix,
+		exact:     map[string]string{},
+		ambiguous: map[string][]string{},
+		levels:    map[string]any{},
+	}
+
+	byName := map[string][]string{}
+	for _, key := range keys {
+		name := envVarName(key)
+		if !slices.Contains(byName[name], key) {
+			byName[name] = append(byName[name], key)
+		}
+
+		parts := strings.Split(key, delim)
+		addLevel(m.levels, parts[:len(parts)-1])
+	}
+
+	for name, candidates := range byName {
+		if len(candidates) == 1 {
+			m.exact[name] = candidates[0]
+			continue
+		}
+		slices.Sort(candidates)
+		m.ambiguous[name] = candidates
+	}
+
+	for _, key := range levelKeys {
+		addLevel(m.levels, strings.Split(key, delim))
+	}
+
+	return m
+}
+
+func addLevel(levels map[string]any, parts []string) {
+	for _, p := range parts {
+		next, found := levels[p].(map[string]any)
+		if !found {
+			next = map[string]any{}
+			levels[p] = next
+		}
+		levels = next
+	}
+}
+
+// envVarName returns the env var name (without prefix) of key.
+func envVarName(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, delim, "_"))
+}
+
+// Map returns the config key of the env var s, set in the environment. An empty string means that the var should be ignored.
+func (m *envKeyMapper) Map(s string) string {
+	return m.mapFrom(SourceEnv, s)
+}
+
+// MapFrom returns the Map function of the env vars of source (e.g. the .env file).
+func (m *envKeyMapper) MapFrom(source string) func(string) string {
+	return func(s string) string { return m.mapFrom(source, s) }
+}
+
+func (m *envKeyMapper) mapFrom(source, s string) string {
+	name := strings.ToUpper(strings.TrimPrefix(s, m.prefix))
+
+	if key, found := m.exact[name]; found {
+		return key
+	}
+
+	switch name {
+	case envConfigFile, envConfigDir, envProfile, envStrict: // they select the config files, they are not config keys.
+		return ""
+	}
+
+	if candidates, isAmbiguous := m.ambiguous[name]; isAmbiguous {
+		m.mu.Lock()
+		m.ambiguousSet = append(m.ambiguousSet, ambiguousEnv{Name: s, Source: source, Keys: candidates})
+		m.mu.Unlock()
+		return ""
 	}
+
+	return buildKey(&strings.Builder{}, delim, "_", m.levels, strings.Split(strings.ToLower(name), "_"))
+}
+
+// AmbiguousSet returns the ambiguous env vars that were set and ignored, sorted by name.
+func (m *envKeyMapper) AmbiguousSet() []ambiguousEnv {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := slices.Clone(m.ambiguousSet)
+	slices.SortStableFunc(out, func(a, b ambiguousEnv) int { return strings.Compare(a.Name, b.Name) })
+
+	return out
+}
+
+// targetKeys returns the keys of every value field of target and, separately, the keys of its map fields.
+func targetKeys(target any) ([]string, []string) {
+	rt := structType(target)
+	if rt == nil {
+		return nil, nil
+	}
+
+	var keys, mapKeys []string
+	walkFields(rt, "", func(key string, sf field) {
+		keys = append(keys, key)
+		if sf.Type.Kind() == reflect.Map {
+			mapKeys = append(mapKeys, key)
+		}
+	})
+
+	return keys, mapKeys
 }
 
 func buildKey(b *strings.Builder, levelSep, wordsSep string, levels map[string]any, parts []string) string {
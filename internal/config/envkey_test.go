@@ -1,8 +1,15 @@
 package config
 
 import (
+	"bytes"
+	"log/slog"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBuildKey(t *testing.T) {
@@ -90,3 +97,78 @@ func TestBuildKey(t *testing.T) {
 		})
 	}
 }
+
+func TestEnvKeyMapper(t *testing.T) {
+	t.Parallel()
+
+	keys := []string{
+		"shutdown_timeout",
+		"http.read_header_timeout",
+		"db.max_open_conns",
+		"db.replica.max_idle_conns",
+		"a.b_c",
+		"a_b.c",
+	}
+	levelKeys := []string{"http.headers"}
+
+	m := newEnvKeyMapper("APP_", keys, levelKeys)
+
+	tests := map[string]string{
+		"APP_SHUTDOWN_TIMEOUT":             "shutdown_timeout",
+		"APP_HTTP_READ_HEADER_TIMEOUT":     "http.read_header_timeout",
+		"APP_DB_MAX_OPEN_CONNS":            "db.max_open_conns",
+		"APP_DB_REPLICA_MAX_IDLE_CONNS":    "db.replica.max_idle_conns",
+		"APP_DB_REPLICA_UNKNOWN_KEY":       "db.replica.unknown_key",
+		"APP_HTTP_HEADERS_X_FORWARDED_FOR": "http.headers.x_forwarded_for",
+		"APP_UNKNOWN_KEY":                  "unknown_key",
+		"APP_A_B_C":                        "",
+	}
+
+	for envVar, expected := range tests {
+		if got := m.Map(envVar); got != expected {
+			t.Errorf("%s: expected %q got %q", envVar, expected, got)
+		}
+	}
+
+	m.MapFrom(".env")("APP_A_B_C")
+
+	expectedAmbiguous := []ambiguousEnv{
+		{Name: "APP_A_B_C", Source: SourceEnv, Keys: []string{"a.b_c", "a_b.c"}},
+		{Name: "APP_A_B_C", Source: ".env", Keys: []string{"a.b_c", "a_b.c"}},
+	}
+	if got := m.AmbiguousSet(); !reflect.DeepEqual(got, expectedAmbiguous) {
+		t.Errorf("expected ambiguous %v got %v", expectedAmbiguous, got)
+	}
+}
+
+func TestLoadAmbiguousEnv(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	logs := &bytes.Buffer{}
+	ctx := zlog.SetInContext(t.Context(), slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	opts := Options{
+		EnvVarPrefix: "TEST_AMBIGUOUS_",
+		Defaults: map[string]any{
+			"name":            "app",
+			"section.port":    8080,
+			"section.type":    "text",
+			"section.timeout": "5s",
+			"a.b_c":           1,
+			"a_b.c":           2,
+		},
+	}
+
+	// ambiguous names that are not set are not reported.
+	_, err := Load(ctx, opts, &testConfig{})
+	require.NoError(t, err)
+	assert.NotContains(t, logs.String(), "ambiguous")
+
+	// set ones are reported along with the layer that set them.
+	writeFile(t, defaultDotEnvFile, "TEST_AMBIGUOUS_A_B_C=3\n")
+	_, err = Load(ctx, opts, &testConfig{})
+	cErr := &Error{}
+	require.ErrorAs(t, err, &cErr)
+	require.Len(t, cErr.Issues, 1)
+	assert.Equal(t, "TEST_AMBIGUOUS_A_B_C", cErr.Issues[0].Key)
+	assert.Equal(t, dotEnvSource(defaultDotEnvFile), cErr.Issues[0].Source)
+}
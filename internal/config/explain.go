@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+)
+
+// Redacted replaces the value of secret keys in every config dump.
+const Redacted = zlog.Redacted
+
+// secretKeyFragments are the key name parts that mark a key as secret, regardless of struct tags.
+//
+//nolint:gochecknoglobals
+var secretKeyFragments = []string{"password", "passwd", "secret", "token", "credential", "api_key", "apikey", "private_key"}
+
+// IsSecretKey reports whether key looks like it holds a secret judging by its name.
+func IsSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, f := range secretKeyFragments {
+		if strings.Contains(key, f) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// secretKeys returns the keys of the fields of target that are of type Secret or tagged with `secret:"true"`.
+func secretKeys(target any) map[string]struct{} {
+	keys := map[string]struct{}{}
+
+	rt := structType(target)
+	if rt == nil {
+		return keys
+	}
+
+	walkFields(rt, "", func(key string, sf field) {
+		if sf.Type == reflect.TypeFor[Secret]() || sf.Tag.Get("secret") == "true" {
+			keys[key] = struct{}{}
+		}
+	})
+
+	return keys
+}
+
+// Entry describes where the effective value of a single key came from.
+type Entry struct {
+	Key       string   `json:"key"`
+	Value     any      `json:"value"`
+	Source    string   `json:"source"`              // the layer that supplied the value.
+	Overrides []string `json:"overrides,omitempty"` // lower priority layers that also had the key.
+}
+
+// IsSecret reports whether the value of key must be redacted.
+func (s *Snapshot) IsSecret(key string) bool {
+	if _, tagged := s.secrets[key]; tagged {
+		return true
+	}
+
+	return IsSecretKey(key)
+}
+
+// Explain returns every effective key (sorted) with its value, the layer it came from and the layers it overrode.
+// Secret values are redacted.
+func (s *Snapshot) Explain() []Entry {
+	keys := s.k.Keys()
+	entries := make([]Entry, 0, len(keys))
+
+	for _, key := range keys {
+		e := Entry{Key: key, Value: s.k.Get(key), Source: SourceDefaults}
+
+		found := false
+		for i := len(s.layers) - 1; i >= 0; i-- {
+			ly := s.layers[i]
+			if !ly.k.Exists(key) {
+				continue
+			}
+			if !found {
+				e.Source = ly.source
+				found = true
+				continue
+			}
+			e.Overrides = append(e.Overrides, ly.source)
+		}
+
+		if s.IsSecret(key) {
+			e.Value = Redacted
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries
+}
+
+// WriteExplain renders entries as a table.
+func WriteExplain(w io.Writer, entries []Entry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE\tOVERRIDES")
+	for _, e := range entries {
+		_, _ = fmt.Fprintf(tw, "%s\t%v\t%s\t%s\n", e.Key, e.Value, e.Source, strings.Join(e.Overrides, ", "))
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotExplain(t *testing.T) {
+	t.Parallel()
+
+	k, lrs := buildLayers(t, []layerInput{
+		{SourceDefaults, map[string]any{"http.port": 8888, "http.ip": "0.0.0.0", "db.dsn": "postgres://"}},
+		{fileSource(defaultConfigFile), map[string]any{"http.port": 9000}},
+		{SourceEnv, map[string]any{"http.port": "9001", "db.password": "pass"}},
+	})
+
+	type target struct {
+		DB struct {
+			DSN string `koanf:"dsn" secret:"true"`
+		} `koanf:"db"`
+	}
+
+	snap := &Snapshot{k: k, layers: lrs, secrets: secretKeys(&target{})}
+
+	expected := []Entry{
+		{Key: "db.dsn", Value: Redacted, Source: SourceDefaults},
+		{Key: "db.password", Value: Redacted, Source: SourceEnv},
+		{Key: "http.ip", Value: "0.0.0.0", Source: SourceDefaults},
+		{Key: "http.port", Value: "9001", Source: SourceEnv, Overrides: []string{fileSource(defaultConfigFile), SourceDefaults}},
+	}
+	assert.Equal(t, expected, snap.Explain())
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteExplain(buf, snap.Explain()))
+	assert.Contains(t, buf.String(), "http.port")
+	assert.NotContains(t, buf.String(), "postgres://")
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/knadh/koanf/parsers/json"
+	"github.com/knadh/koanf/parsers/toml/v2"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/v2"
+)
+
+const (
+	defaultConfigFile = "config.yaml"
+	defaultDotEnvFile = ".env"
+)
+
+// Env vars (after the prefix, e.g. APP_CONFIG_FILE) that select the config files instead of config keys.
+const (
+	envConfigFile = "CONFIG_FILE" // comma separated list of files.
+	envConfigDir  = "CONFIG_DIR"
+	envProfile    = "PROFILE"
+	envStrict     = "CONFIG_STRICT"
+)
+
+// Options controls where Load reads the configuration from.
+type Options struct {
+	// EnvVarPrefix is the prefix of the env vars (and .env entries) that override config keys, e.g. APP_.
+	EnvVarPrefix string
+
+	// Defaults is the lowest priority layer.
+	Defaults map[string]any
+
+	// Files are loaded in order, each one overriding the previous one. The format is picked by the extension
+	// (.yaml/.yml, .toml, .json). When empty, <prefix>CONFIG_FILE is used, or else the optional config.yaml.
+	Files []string
+
+	// Dir is a conf.d style directory. Every supported file in it is loaded, in lexical order, after Files.
+	// When empty, <prefix>CONFIG_DIR is used.
+	Dir string
+
+	// Profile, when set, overlays <name>.<profile>.<ext> (if exists) right after each one of Files,
+	// e.g. config.prod.yaml after config.yaml. When empty, <prefix>PROFILE is used.
+	Profile string
+
+	// DotEnvFile is the optional .env file that is loaded last. Defaults to .env.
+	DotEnvFile string
+
+	// Strict controls how unknown keys are treated. When empty, <prefix>CONFIG_STRICT is used,
+	// or else StrictAuto.
+	Strict StrictMode
+}
+
+// resolved fills the unset options from env vars and defaults.
+func (o Options) resolved() Options {
+	if len(o.Files) == 0 {
+		for f := range strings.SplitSeq(os.Getenv(o.EnvVarPrefix+envConfigFile), ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				o.Files = append(o.Files, f)
+			}
+		}
+	}
+	if o.Dir == "" {
+		o.Dir = os.Getenv(o.EnvVarPrefix + envConfigDir)
+	}
+	if o.Profile == "" {
+		o.Profile = os.Getenv(o.EnvVarPrefix + envProfile)
+	}
+	if o.Strict == StrictAuto {
+		o.Strict = StrictMode(os.Getenv(o.EnvVarPrefix + envStrict))
+	}
+	if o.DotEnvFile == "" {
+		o.DotEnvFile = defaultDotEnvFile
+	}
+
+	return o
+}
+
+// configFile is a file layer. Missing optional files are skipped.
+type configFile struct {
+	path     string
+	optional bool
+}
+
+// configFiles returns the file layers in loading order.
+func (o Options) configFiles() ([]configFile, error) {
+	var files []configFile
+
+	if len(o.Files) == 0 {
+		files = append(files, configFile{path: defaultConfigFile, optional: true})
+	} else {
+		for _, f := range o.Files {
+			files = append(files, configFile{path: f})
+		}
+	}
+
+	if o.Profile != "" {
+		withProfiles := make([]configFile, 0, len(files)*2)
+		for _, f := range files {
+			withProfiles = append(withProfiles, f, configFile{path: profilePath(f.path, o.Profile), optional: true})
+		}
+		files = withProfiles
+	}
+
+	if o.Dir != "" {
+		entries, err := os.ReadDir(o.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("config dir: %w", err)
+		}
+
+		var names []string
+		for _, e := range entries {
+			if !e.IsDir() && isSupportedFile(e.Name()) {
+				names = append(names, e.Name())
+			}
+		}
+		slices.Sort(names)
+
+		for _, n := range names {
+			files = append(files, configFile{path: filepath.Join(o.Dir, n)})
+		}
+	}
+
+	return files, nil
+}
+
+// profilePath returns the profile overlay of path, e.g. config.yaml -> config.prod.yaml.
+func profilePath(path, profile string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
+
+func isSupportedFile(path string) bool {
+	_, err := parserFor(path)
+	return err == nil
+}
+
+func parserFor(path string) (koanf.Parser, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Parser(), nil
+	case ".toml":
+		return toml.Parser(), nil
+	case ".json":
+		return json.Parser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q", path)
+	}
+}
+
+func fileSource(path string) string {
+	return "file:" + path
+}
+
+func dotEnvSource(path string) string {
+	return "dotenv:" + path
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFiles(t *testing.T) {
+	dir := t.TempDir()
+	confD := filepath.Join(dir, "conf.d")
+	require.NoError(t, os.Mkdir(confD, 0o700))
+
+	writeFile(t, filepath.Join(dir, "base.yaml"), "name: base\nsection:\n  type: text\n  port: 1\n")
+	writeFile(t, filepath.Join(dir, "base.prod.yaml"), "section:\n  port: 2\n")
+	writeFile(t, filepath.Join(dir, "extra.toml"), "[section]\ntimeout = \"3s\"\n")
+	writeFile(t, filepath.Join(confD, "20-b.json"), `{"section": {"port": 4}}`)
+	writeFile(t, filepath.Join(confD, "10-a.yaml"), "section:\n  port: 3\n  type: json\n")
+	writeFile(t, filepath.Join(confD, "README.md"), "ignored")
+
+	opts := Options{
+		EnvVarPrefix: "TEST_FILES_",
+		Files:        []string{filepath.Join(dir, "base.yaml"), filepath.Join(dir, "extra.toml")},
+		Dir:          confD,
+		Profile:      "prod",
+		DotEnvFile:   filepath.Join(dir, ".env"),
+	}
+
+	var cnf testConfig
+	snap, err := Load(t.Context(), opts, &cnf)
+	require.NoError(t, err)
+
+	assert.Equal(t, testConfig{Name: "base", Section: testSection{Port: 4, Type: "json", Timeout: 3e9}}, cnf)
+	assert.Equal(t, fileSource(filepath.Join(confD, "20-b.json")), snap.Source("section.port"))
+	assert.Equal(t, fileSource(filepath.Join(dir, "extra.toml")), snap.Source("section.timeout"))
+
+	entries := snap.Explain()
+	for _, e := range entries {
+		if e.Key == "section.port" {
+			assert.Equal(t, []string{
+				fileSource(filepath.Join(confD, "10-a.yaml")),
+				fileSource(filepath.Join(dir, "base.prod.yaml")),
+				fileSource(filepath.Join(dir, "base.yaml")),
+			}, e.Overrides)
+		}
+	}
+}
+
+func TestLoadFilesFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.yaml"), "name: a\nsection:\n  type: text\n  port: 1\n  timeout: 1s\n")
+	writeFile(t, filepath.Join(dir, "b.yaml"), "name: b\n")
+	writeFile(t, filepath.Join(dir, "b.staging.yaml"), "name: b-staging\n")
+
+	t.Setenv("TEST_ENVFILES_CONFIG_FILE", filepath.Join(dir, "a.yaml")+","+filepath.Join(dir, "b.yaml"))
+	t.Setenv("TEST_ENVFILES_PROFILE", "staging")
+
+	var cnf testConfig
+	snap, err := Load(t.Context(), Options{EnvVarPrefix: "TEST_ENVFILES_", DotEnvFile: filepath.Join(dir, ".env")}, &cnf)
+	require.NoError(t, err)
+	assert.Equal(t, "b-staging", cnf.Name)
+	assert.False(t, snap.Koanf().Exists("profile"))
+	assert.False(t, snap.Koanf().Exists("config_file"))
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	t.Parallel()
+
+	var cnf testConfig
+	_, err := Load(t.Context(), Options{Files: []string{filepath.Join(t.TempDir(), "missing.yaml")}}, &cnf)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestProfilePath(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "config.prod.yaml", profilePath("config.yaml", "prod"))
+	assert.Equal(t, "/etc/app/app.dev.toml", profilePath("/etc/app/app.toml", "dev"))
+}
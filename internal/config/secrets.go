@@ -0,0 +1,147 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/knadh/koanf/v2"
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+)
+
+// Secret references that are resolved, during Load, in any string value:
+//
+//	file:///run/secrets/db_password   the content of the file (trailing new lines are trimmed).
+//	env://OTHER_VAR                   the value of another env var.
+//	base64:c2VjcmV0                   the decoded value.
+//
+// The keys of resolved references are marked as secret and redacted in every config dump, and the resolved values
+// are redacted from the log attributes (see zlog.RedactValues).
+const (
+	secretRefFile   = "file://"
+	secretRefEnv    = "env://"
+	secretRefBase64 = "base64:"
+)
+
+var errSecretRef = errors.New("cannot resolve secret reference")
+
+// Secret is a config value that is never rendered in logs, fmt or json output. Use Value to get the actual value.
+// Fields of type Secret are redacted in config dumps as well.
+type Secret string
+
+// Value returns the actual secret value.
+func (s Secret) Value() string { return string(s) }
+
+func (s Secret) String() string { return Redacted }
+
+func (s Secret) GoString() string { return Redacted }
+
+func (s Secret) LogValue() slog.Value { return slog.StringValue(Redacted) }
+
+func (s Secret) MarshalJSON() ([]byte, error) { return []byte(`"` + Redacted + `"`), nil }
+
+func (s *Secret) UnmarshalText(text []byte) error {
+	*s = Secret(text)
+	return nil
+}
+
+// isSecretRef reports whether s is a secret reference.
+func isSecretRef(s string) bool {
+	return strings.HasPrefix(s, secretRefFile) || strings.HasPrefix(s, secretRefEnv) || strings.HasPrefix(s, secretRefBase64)
+}
+
+// resolveSecretRef returns the value that the reference ref points to, and registers it for redaction in the logs.
+func resolveSecretRef(ref string) (string, error) {
+	v, err := readSecretRef(ref)
+	if err == nil {
+		zlog.RedactValues(v)
+	}
+
+	return v, err
+}
+
+func readSecretRef(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, secretRefFile):
+		path := strings.TrimPrefix(ref, secretRefFile)
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("%w %s: %w", errSecretRef, ref, err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+
+	case strings.HasPrefix(ref, secretRefEnv):
+		name := strings.TrimPrefix(ref, secretRefEnv)
+		v, found := os.LookupEnv(name)
+		if !found {
+			return "", fmt.Errorf("%w %s: env var %s is not set", errSecretRef, ref, name)
+		}
+		return v, nil
+
+	case strings.HasPrefix(ref, secretRefBase64):
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ref, secretRefBase64))
+		if err != nil {
+			// the reference itself may be the secret, don't echo it back.
+			return "", fmt.Errorf("%w base64:...: %w", errSecretRef, err)
+		}
+		return string(b), nil
+
+	default:
+		return ref, nil
+	}
+}
+
+// resolveSecrets replaces, in k, every secret reference with the value it points to.
+// It returns the keys that were resolved and an issue for every reference that could not be resolved.
+func resolveSecrets(k *koanf.Koanf, lrs layers) (map[string]struct{}, []Issue) {
+	resolved := map[string]struct{}{}
+	var issues []Issue
+
+	for _, key := range k.Keys() {
+		var (
+			value any
+			found bool
+			err   error
+		)
+
+		switch v := k.Get(key).(type) {
+		case string:
+			if !isSecretRef(v) {
+				continue
+			}
+			found = true
+			value, err = resolveSecretRef(v)
+
+		case []any:
+			items := make([]any, len(v))
+			for i, item := range v {
+				items[i] = item
+				s, isString := item.(string)
+				if !isString || !isSecretRef(s) {
+					continue
+				}
+				found = true
+				if items[i], err = resolveSecretRef(s); err != nil {
+					break
+				}
+			}
+			value = items
+		}
+
+		if !found {
+			continue
+		}
+
+		resolved[key] = struct{}{}
+		if err != nil {
+			issues = append(issues, Issue{Key: key, Source: lrs.sourceOf(key), Err: err})
+			continue
+		}
+		_ = k.Set(key, value)
+	}
+
+	return resolved, issues
+}
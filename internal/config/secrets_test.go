@@ -0,0 +1,120 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSecretRefs(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "db_password"), "s3cr3t-password\n")
+	t.Setenv("TEST_SECRET_REF_OTHER", "from-env-token")
+
+	type target struct {
+		Password Secret   `koanf:"password" validate:"required"`
+		Token    string   `koanf:"token"`
+		Keys     []string `koanf:"keys"`
+		Plain    string   `koanf:"plain"`
+	}
+
+	opts := Options{
+		EnvVarPrefix: "TEST_SECRETS_",
+		Defaults: map[string]any{
+			"password": "file://" + filepath.Join(dir, "db_password"),
+			"token":    "env://TEST_SECRET_REF_OTHER",
+			"keys":     []any{"base64:a2V5LTE=", "plain"},
+			"plain":    "value",
+		},
+		DotEnvFile: filepath.Join(dir, ".env"),
+	}
+
+	var cnf target
+	snap, err := Load(t.Context(), opts, &cnf)
+	require.NoError(t, err)
+
+	assert.Equal(t, "s3cr3t-password", cnf.Password.Value())
+	assert.Equal(t, "from-env-token", cnf.Token)
+	assert.Equal(t, []string{"key-1", "plain"}, cnf.Keys)
+
+	for _, e := range snap.Explain() {
+		if e.Key == "plain" {
+			assert.Equal(t, "value", e.Value)
+			continue
+		}
+		assert.Equal(t, Redacted, e.Value, e.Key)
+	}
+
+	// the resolved values are redacted from the log attributes, errors included.
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{ReplaceAttr: zlog.ReplaceAttr}))
+	logger.Info("loaded",
+		slog.String("token", cnf.Token),
+		zlog.Error(fmt.Errorf("login with %s failed", cnf.Password.Value())),
+		slog.Any("cause", fmt.Errorf("token %s expired", cnf.Token)),
+		slog.String("keys", "monkey-12"),
+	)
+	for _, secret := range []string{"s3cr3t-password", "from-env-token"} {
+		assert.NotContains(t, buf.String(), secret)
+	}
+	assert.Contains(t, buf.String(), `"token":"`+zlog.Redacted+`"`)
+	assert.Contains(t, buf.String(), `"cause":"token `+zlog.Redacted+` expired"`)
+
+	// values shorter than zlog.MinRedactedLength (key-1) are not redacted, so they do not mangle unrelated text.
+	assert.Contains(t, buf.String(), `"keys":"monkey-12"`)
+}
+
+func TestLoadSecretRefsErrors(t *testing.T) {
+	t.Parallel()
+
+	opts := Options{
+		EnvVarPrefix: "TEST_SECRETS_ERR_",
+		Defaults: map[string]any{
+			"a": "file:///does/not/exist",
+			"b": "env://TEST_SECRETS_ERR_MISSING",
+			"c": "base64:!!!",
+		},
+		DotEnvFile: filepath.Join(t.TempDir(), ".env"),
+	}
+
+	var cnf struct {
+		A string `koanf:"a"`
+		B string `koanf:"b"`
+		C string `koanf:"c"`
+	}
+	_, err := Load(t.Context(), opts, &cnf)
+
+	var cErr *Error
+	require.True(t, errors.As(err, &cErr))
+	require.Len(t, cErr.Issues, 3)
+	for i, key := range []string{"a", "b", "c"} {
+		assert.Equal(t, key, cErr.Issues[i].Key)
+		assert.ErrorIs(t, cErr.Issues[i], errSecretRef)
+	}
+	assert.NotContains(t, err.Error(), "!!!")
+}
+
+func TestSecretRendering(t *testing.T) {
+	t.Parallel()
+
+	s := struct {
+		Password Secret `json:"password"`
+	}{Password: "s3cr3t"}
+
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "s3cr3t")
+	assert.NotContains(t, fmt.Sprintf("%v %+v %#v %s", s, s, s, s.Password), "s3cr3t")
+
+	buf := &bytes.Buffer{}
+	slog.New(slog.NewJSONHandler(buf, nil)).Info("test", slog.Any("password", s.Password), slog.Any("config", s))
+	assert.NotContains(t, buf.String(), "s3cr3t")
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+)
+
+// StrictMode controls how keys that are not registered (by the config structs or the defaults) are treated.
+type StrictMode string
+
+const (
+	// StrictAuto is StrictError for production profiles (prod, production) and StrictWarn otherwise.
+	StrictAuto  StrictMode = ""
+	StrictOff   StrictMode = "off"   // unknown keys are ignored.
+	StrictWarn  StrictMode = "warn"  // unknown keys are logged.
+	StrictError StrictMode = "error" // unknown keys fail Load.
+)
+
+//nolint:gochecknoglobals
+var productionProfiles = []string{"prod", "production"}
+
+// effective resolves StrictAuto according to profile.
+func (m StrictMode) effective(profile string) StrictMode {
+	if m != StrictAuto {
+		return m
+	}
+	if slices.Contains(productionProfiles, strings.ToLower(profile)) {
+		return StrictError
+	}
+
+	return StrictWarn
+}
+
+func (m StrictMode) validate() error {
+	switch m {
+	case StrictAuto, StrictOff, StrictWarn, StrictError:
+		return nil
+	default:
+		return fmt.Errorf("invalid config strict mode %q (expected off, warn or error)", string(m))
+	}
+}
+
+// checkUnknownKeys compares the keys of every layer (except defaults) against the known keys.
+// Depending on the strict mode, unknown keys are logged or returned as issues.
+func checkUnknownKeys(ctx context.Context, opts Options, lrs layers, defaults map[string]any, target any) []Issue {
+	mode := opts.Strict.effective(opts.Profile)
+	if mode == StrictOff {
+		return nil
+	}
+
+	keys, mapKeys := knownKeys(defaults, target)
+	known := map[string]struct{}{}
+	for _, k := range keys {
+		known[k] = struct{}{}
+	}
+	candidates := slices.Sorted(maps.Keys(known))
+
+	isKnown := func(key string) bool {
+		if _, found := known[key]; found {
+			return true
+		}
+		return slices.ContainsFunc(mapKeys, func(mk string) bool { return strings.HasPrefix(key, mk+delim) })
+	}
+
+	var issues []Issue
+	for _, ly := range lrs {
+		if ly.source == SourceDefaults {
+			continue
+		}
+
+		for _, key := range ly.k.Keys() {
+			if isKnown(key) {
+				continue
+			}
+
+			msg := "unknown key"
+			if suggestion := closest(key, candidates); suggestion != "" {
+				if ly.source == SourceEnv || strings.HasPrefix(ly.source, dotEnvSource("")) {
+					msg += fmt.Sprintf(", did you mean %s (%s)?", suggestion, opts.EnvVarPrefix+envVarName(suggestion))
+				} else {
+					msg += fmt.Sprintf(", did you mean %s?", suggestion)
+				}
+			}
+
+			issue := Issue{Key: key, Source: ly.source, Err: fmt.Errorf("%s", msg)}
+			if mode == StrictWarn {
+				zlog.GetFromContext(ctx).WarnContext(ctx, "unknown config key", slog.String("key", key), slog.String("source", ly.source), slog.String("detail", msg))
+				continue
+			}
+			issues = append(issues, issue)
+		}
+	}
+
+	return issues
+}
+
+// closest returns the candidate with the smallest edit distance from key, if it is close enough to be a typo.
+func closest(key string, candidates []string) string {
+	best, bestDist := "", -1
+	for _, c := range candidates {
+		d := levenshtein(key, c)
+		if bestDist == -1 || d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+
+	if bestDist == -1 || bestDist > max(2, len(key)/4) {
+		return ""
+	}
+
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadStrict(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "name: app\nsection:\n  type: text\n  timeout: 1s\n  prot: 80\n")
+	writeFile(t, filepath.Join(dir, ".env"), "TEST_STRICT_SECTION_TIMOUT=2s\n")
+	t.Setenv("TEST_STRICT_SECTION_PORT", "81")
+	t.Setenv("TEST_STRICT_SECTION_NAMS", "a,b")
+
+	opts := Options{
+		EnvVarPrefix: "TEST_STRICT_",
+		Files:        []string{filepath.Join(dir, "config.yaml")},
+		DotEnvFile:   filepath.Join(dir, ".env"),
+	}
+
+	tests := map[string]struct {
+		strict   StrictMode
+		profile  string
+		expected []string
+	}{
+		"auto, not production": {
+			strict: StrictAuto,
+		},
+		"auto, production": {
+			strict:  StrictAuto,
+			profile: "prod",
+			expected: []string{
+				"section.prot (from file:" + filepath.Join(dir, "config.yaml") + "): unknown key, did you mean section.port?",
+				"section.nams (from env): unknown key, did you mean section.names (TEST_STRICT_SECTION_NAMES)?",
+				"section.timout (from dotenv:" + filepath.Join(dir, ".env") + "): unknown key, did you mean section.timeout (TEST_STRICT_SECTION_TIMEOUT)?",
+			},
+		},
+		"off, production": {
+			strict:  StrictOff,
+			profile: "prod",
+		},
+		"warn": {
+			strict: StrictWarn,
+		},
+		"error": {
+			strict:   StrictError,
+			expected: []string{"section.prot", "section.nams", "section.timout"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := opts
+			o.Strict = tc.strict
+			o.Profile = tc.profile
+
+			var cnf testConfig
+			_, err := Load(t.Context(), o, &cnf)
+			if len(tc.expected) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			var cErr *Error
+			require.True(t, errors.As(err, &cErr))
+			require.Len(t, cErr.Issues, len(tc.expected))
+			for i, e := range tc.expected {
+				assert.Contains(t, cErr.Issues[i].Error(), e)
+			}
+		})
+	}
+}
+
+func TestLoadStrictInvalidMode(t *testing.T) {
+	t.Parallel()
+
+	var cnf testConfig
+	_, err := Load(t.Context(), Options{Strict: "maybe"}, &cnf)
+	require.Error(t, err)
+}
+
+func TestClosest(t *testing.T) {
+	t.Parallel()
+
+	candidates := []string{"http.port", "http.ip", "http.read_header_timeout", "log.level"}
+
+	assert.Equal(t, "http.read_header_timeout", closest("http.read_header_timout", candidates))
+	assert.Equal(t, "http.port", closest("http.prot", candidates))
+	assert.Empty(t, closest("database.dsn", candidates))
+}
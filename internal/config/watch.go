@@ -0,0 +1,264 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+)
+
+// reloadDebounce groups the bursts of fs events that a single save usually produces.
+const reloadDebounce = 100 * time.Millisecond
+
+type subscriber[T any] struct {
+	prefix string
+	fn     func(context.Context, *T)
+}
+
+// Watcher holds the latest valid configuration of type T and reloads it when the config files change.
+// An invalid reload is logged and discarded; the last valid configuration stays active.
+type Watcher[T any] struct {
+	opts Options
+
+	current  atomic.Pointer[T]
+	snapshot atomic.Pointer[Snapshot]
+
+	reloadMu    sync.Mutex // serializes reloads, along with the notifications of the subscribers.
+	mu          sync.Mutex // guards subscribers.
+	subscribers []subscriber[T]
+}
+
+// NewWatcher performs the initial Load. It fails if the initial configuration is invalid.
+func NewWatcher[T any](ctx context.Context, opts Options) (*Watcher[T], error) {
+	w := &Watcher[T]{
+		opts: opts.resolved(),
+	}
+
+	cnf := new(T)
+	snap, err := Load(ctx, w.opts, cnf)
+	w.snapshot.Store(snap)
+	if err != nil {
+		return w, err
+	}
+	w.current.Store(cnf)
+
+	return w, nil
+}
+
+// Current returns the active configuration. The returned value must be treated as read only.
+func (w *Watcher[T]) Current() *T {
+	return w.current.Load()
+}
+
+// Snapshot returns the snapshot of the active configuration.
+func (w *Watcher[T]) Snapshot() *Snapshot {
+	return w.snapshot.Load()
+}
+
+// Subscribe registers fn to be called, after a successful reload, when any key under prefix
+// (e.g. "log.level" or "http") has changed. An empty prefix matches every key.
+func (w *Watcher[T]) Subscribe(prefix string, fn func(ctx context.Context, cnf *T)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, subscriber[T]{prefix: prefix, fn: fn})
+}
+
+// Reload loads and validates the configuration again and, if valid, swaps it in and notifies the subscribers. The
+// changed keys without subscribers are logged as requiring a restart.
+func (w *Watcher[T]) Reload(ctx context.Context) error {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	cnf := new(T)
+	snap, err := Load(ctx, w.opts, cnf)
+	if err != nil {
+		return err
+	}
+
+	previous := w.snapshot.Load()
+	w.current.Store(cnf)
+	w.snapshot.Store(snap)
+
+	changed := changedKeys(previous, snap)
+	if len(changed) == 0 {
+		return nil
+	}
+
+	// the subscribers are called without holding mu, so that they may Subscribe.
+	w.mu.Lock()
+	subscribers := slices.Clone(w.subscribers)
+	w.mu.Unlock()
+
+	// the keys without subscribers are not applied until the next start.
+	var applied, restart []string
+	for _, key := range changed {
+		if slices.ContainsFunc(subscribers, func(s subscriber[T]) bool { return hasPrefix(key, s.prefix) }) {
+			applied = append(applied, key)
+		} else {
+			restart = append(restart, key)
+		}
+	}
+
+	logger := zlog.GetFromContext(ctx)
+	if len(applied) > 0 {
+		logger.InfoContext(ctx, "configuration reloaded", slog.Any("changed", applied))
+	}
+	if len(restart) > 0 {
+		logger.WarnContext(ctx, "configuration changed, a restart is required to apply it", slog.Any("changed", restart))
+	}
+
+	for _, s := range subscribers {
+		if slices.ContainsFunc(changed, func(key string) bool { return hasPrefix(key, s.prefix) }) {
+			s.fn(ctx, cnf)
+		}
+	}
+
+	return nil
+}
+
+// Start watches the config files (and the config dir) and reloads on every change, until ctx is done.
+func (w *Watcher[T]) Start(ctx context.Context) error {
+	files, err := w.opts.configFiles()
+	if err != nil {
+		return err
+	}
+
+	paths := []string{w.opts.DotEnvFile}
+	for _, f := range files {
+		paths = append(paths, f.path)
+	}
+
+	t := watchTargets{files: map[string]struct{}{}, dirs: map[string]struct{}{}}
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		t.files[abs] = struct{}{}
+	}
+	if w.opts.Dir != "" {
+		abs, err := filepath.Abs(w.opts.Dir)
+		if err != nil {
+			return err
+		}
+		t.dirs[abs] = struct{}{}
+	}
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// the parent directories are watched (instead of the files) so that files which are
+	// created later, or replaced through rename / symlink swap, are picked up as well.
+	for p := range t.files {
+		if err := fw.Add(filepath.Dir(p)); err != nil {
+			_ = fw.Close()
+			return err
+		}
+	}
+	for d := range t.dirs {
+		if err := fw.Add(d); err != nil {
+			_ = fw.Close()
+			return err
+		}
+	}
+
+	go w.watchLoop(ctx, fw, t)
+
+	return nil
+}
+
+type watchTargets struct {
+	files map[string]struct{} // absolute paths of files.
+	dirs  map[string]struct{} // absolute paths of conf.d dirs.
+}
+
+func (t watchTargets) matches(name string) bool {
+	name = filepath.Clean(name)
+	if _, found := t.files[name]; found {
+		return true
+	}
+	if _, found := t.dirs[filepath.Dir(name)]; found {
+		return isSupportedFile(name)
+	}
+
+	return false
+}
+
+func (w *Watcher[T]) watchLoop(ctx context.Context, fw *fsnotify.Watcher, t watchTargets) {
+	logger := zlog.GetFromContext(ctx)
+	defer func() { _ = fw.Close() }()
+
+	var debounce *time.Timer
+	reload := func() {
+		if err := w.Reload(ctx); err != nil {
+			logger.ErrorContext(ctx, "invalid configuration reload, keeping the last valid configuration", zlog.Error(err))
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if debounce != nil {
+				debounce.Stop()
+			}
+			return
+
+		case event, ok := <-fw.Events:
+			if !ok {
+				return
+			}
+			if !t.matches(event.Name) || event.Has(fsnotify.Chmod) {
+				continue
+			}
+			if debounce == nil {
+				debounce = time.AfterFunc(reloadDebounce, reload)
+			} else {
+				debounce.Reset(reloadDebounce)
+			}
+
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return
+			}
+			logger.WarnContext(ctx, "config files watcher error", zlog.Error(err))
+		}
+	}
+}
+
+func hasPrefix(key, prefix string) bool {
+	return prefix == "" || key == prefix || strings.HasPrefix(key, prefix+delim)
+}
+
+// changedKeys returns the sorted keys whose effective value differs between the two snapshots.
+func changedKeys(previous, current *Snapshot) []string {
+	var prev, curr map[string]any
+	if previous != nil {
+		prev = previous.k.All()
+	}
+	curr = current.k.All()
+
+	var changed []string
+	for key, v := range curr {
+		if pv, found := prev[key]; !found || !reflect.DeepEqual(pv, v) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range prev {
+		if _, found := curr[key]; !found {
+			changed = append(changed, key)
+		}
+	}
+	slices.Sort(changed)
+
+	return changed
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(name, []byte(content), 0o600))
+}
+
+func TestWatcherReload(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	defaults := map[string]any{
+		"name":            "app",
+		"section.port":    8080,
+		"section.type":    "text",
+		"section.timeout": "5s",
+	}
+
+	w, err := NewWatcher[testConfig](t.Context(), Options{EnvVarPrefix: "TEST_WATCHER_", Defaults: defaults})
+	require.NoError(t, err)
+	assert.Equal(t, "text", w.Current().Section.Type)
+
+	calls := map[string]int{}
+	for _, prefix := range []string{"section.type", "section", "name", ""} {
+		w.Subscribe(prefix, func(_ context.Context, _ *testConfig) { calls[prefix]++ })
+	}
+
+	// valid change
+	writeFile(t, defaultConfigFile, "section:\n  type: json\n")
+	require.NoError(t, w.Reload(t.Context()))
+	assert.Equal(t, "json", w.Current().Section.Type)
+	assert.Equal(t, fileSource(defaultConfigFile), w.Snapshot().Source("section.type"))
+	assert.Equal(t, map[string]int{"section.type": 1, "section": 1, "": 1}, calls)
+
+	// invalid change keeps the last valid config
+	writeFile(t, defaultConfigFile, "section:\n  type: xml\n")
+	require.Error(t, w.Reload(t.Context()))
+	assert.Equal(t, "json", w.Current().Section.Type)
+	assert.Equal(t, map[string]int{"section.type": 1, "section": 1, "": 1}, calls)
+
+	// no changes, no notifications
+	writeFile(t, defaultConfigFile, "section:\n  type: json\n")
+	require.NoError(t, w.Reload(t.Context()))
+	assert.Equal(t, map[string]int{"section.type": 1, "section": 1, "": 1}, calls)
+
+	// subscribers may subscribe.
+	w.Subscribe("name", func(_ context.Context, _ *testConfig) {
+		w.Subscribe("name", func(_ context.Context, _ *testConfig) { calls["nested"]++ })
+	})
+	writeFile(t, defaultConfigFile, "name: other\nsection:\n  type: json\n")
+	require.NoError(t, w.Reload(t.Context()))
+	writeFile(t, defaultConfigFile, "name: again\nsection:\n  type: json\n")
+	require.NoError(t, w.Reload(t.Context()))
+	assert.Equal(t, 1, calls["nested"])
+}
+
+func TestWatcherReloadLogs(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	w, err := NewWatcher[testConfig](t.Context(), Options{
+		EnvVarPrefix: "TEST_WATCHER_",
+		Defaults: map[string]any{
+			"name":            "app",
+			"section.port":    8080,
+			"section.type":    "text",
+			"section.timeout": "5s",
+		},
+	})
+	require.NoError(t, err)
+	w.Subscribe("section.type", func(_ context.Context, _ *testConfig) {})
+
+	logs := &bytes.Buffer{}
+	ctx := zlog.SetInContext(t.Context(), slog.New(slog.NewJSONHandler(logs, nil)))
+
+	writeFile(t, defaultConfigFile, "name: other\nsection:\n  type: json\n")
+	require.NoError(t, w.Reload(ctx))
+
+	records := map[string][]any{}
+	for line := range bytes.Lines(logs.Bytes()) {
+		record := map[string]any{}
+		require.NoError(t, json.Unmarshal(line, &record))
+		msg, _ := record["msg"].(string)
+		if changed, found := record["changed"].([]any); found {
+			records[msg] = changed
+		}
+	}
+
+	// only the keys with subscribers are applied live.
+	assert.Equal(t, map[string][]any{
+		"configuration reloaded":                                   {"section.type"},
+		"configuration changed, a restart is required to apply it": {"name"},
+	}, records)
+}
+
+func TestWatcherStart(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	w, err := NewWatcher[testConfig](t.Context(), Options{
+		EnvVarPrefix: "TEST_WATCHER_",
+		Defaults: map[string]any{
+			"name":            "app",
+			"section.port":    8080,
+			"section.type":    "text",
+			"section.timeout": "5s",
+		},
+	})
+	require.NoError(t, err)
+
+	changed := make(chan string, 1)
+	w.Subscribe("name", func(_ context.Context, c *testConfig) { changed <- c.Name })
+
+	require.NoError(t, w.Start(t.Context()))
+
+	writeFile(t, defaultDotEnvFile, "TEST_WATCHER_NAME=other\n")
+
+	select {
+	case name := <-changed:
+		assert.Equal(t, "other", name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for config reload")
+	}
+}
@@ -0,0 +1,274 @@
+// Package validate implements a small, tag driven struct validator.
+//
+// Rules are declared in the `validate` struct tag as a comma separated list:
+//
+//	Port    int64         `koanf:"port"    validate:"min=1,max=65535"`
+//	Type    string        `koanf:"type"    validate:"required,oneof=json text"`
+//	Timeout time.Duration `koanf:"timeout" validate:"gt=0"`
+//
+// Supported rules are required, oneof, min, max, gt, gte, lt and lte. The
+// comparison rules apply to the numeric value of numbers (durations included,
+// in which case the parameter is a duration string) and to the length of
+// strings, slices and maps. gtfield, gtefield, ltfield and ltefield compare a
+// number to another field of the same struct, named by its Go name:
+//
+//	MaxLimit int `koanf:"max_limit" validate:"gtefield=MinLimit"`
+//
+// Fields of embedded structs are validated as fields of the embedding struct.
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tagName = "validate"
+
+// FieldError describes a single failed rule.
+type FieldError struct {
+	Path    string // e.g. http.port
+	Rule    string // e.g. max
+	Message string // e.g. must be at most 65535
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Errors is the list of all the failed rules of a struct.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	s := make([]string, 0, len(e))
+	for _, fe := range e {
+		s = append(s, fe.Error())
+	}
+
+	return strings.Join(s, "; ")
+}
+
+// NameFunc returns the name of a struct field as it should appear in error paths.
+// An empty name or "-" excludes the field (and its nested fields) from validation.
+type NameFunc func(reflect.StructField) string
+
+// TagName returns a NameFunc that uses the given struct tag (e.g. `koanf` or `json`)
+// and falls back to the lower-cased field name.
+func TagName(tag string) NameFunc {
+	return func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "" {
+			return strings.ToLower(f.Name)
+		}
+
+		return name
+	}
+}
+
+// Struct validates v (a struct or a pointer to struct) and every nested struct in it.
+// Paths in the returned errors are built by joining the field names with sep, starting from prefix.
+func Struct(v any, name NameFunc, prefix, sep string) Errors {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs Errors
+	walk(rv, name, prefix, sep, &errs)
+
+	return errs
+}
+
+//nolint:gochecknoglobals
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	timeType     = reflect.TypeFor[time.Time]()
+)
+
+func walk(rv reflect.Value, name NameFunc, prefix, sep string, errs *Errors) {
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		sf := rt.Field(i)
+
+		// embedded structs are flattened: their fields are validated as fields of rv.
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			walk(rv.Field(i), name, prefix, sep, errs)
+			continue
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		n := name(sf)
+		if n == "" || n == "-" {
+			continue
+		}
+
+		path := n
+		if prefix != "" {
+			path = prefix + sep + n
+		}
+
+		fv := rv.Field(i)
+		if rules := sf.Tag.Get(tagName); rules != "" {
+			if fe, failed := check(rv, fv, rules, name); failed {
+				fe.Path = path
+				*errs = append(*errs, fe)
+				continue
+			}
+		}
+
+		nested := reflect.Indirect(fv)
+		if nested.Kind() == reflect.Struct && nested.Type() != timeType {
+			walk(nested, name, path, sep, errs)
+		}
+	}
+}
+
+// check applies the rules to fv, a field of the struct parent, and returns the first one that fails.
+func check(parent, fv reflect.Value, rules string, name NameFunc) (FieldError, bool) {
+	for rule := range strings.SplitSeq(rules, ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if rule == "" {
+			continue
+		}
+
+		if msg := apply(parent, fv, rule, param, name); msg != "" {
+			return FieldError{Rule: rule, Message: msg}, true
+		}
+	}
+
+	return FieldError{}, false
+}
+
+func apply(parent, fv reflect.Value, rule, param string, name NameFunc) string {
+	switch rule {
+	case "required":
+		if isEmpty(fv) {
+			return "is required"
+		}
+	case "oneof":
+		allowed := strings.Fields(param)
+		got := fmt.Sprint(fv) // fv may be read only (a field of an unexported embedded struct).
+		for _, a := range allowed {
+			if a == got {
+				return ""
+			}
+		}
+
+		return fmt.Sprintf("must be one of [%s], got %q", strings.Join(allowed, " "), got)
+	case "min", "max", "gt", "gte", "lt", "lte":
+		return compare(fv, rule, param)
+	case "gtfield", "gtefield", "ltfield", "ltefield":
+		return compareField(parent, fv, rule, param, name)
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", rule))
+	}
+
+	return ""
+}
+
+func isEmpty(fv reflect.Value) bool {
+	switch fv.Kind() { //nolint:exhaustive
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return fv.Len() == 0
+	default:
+		return fv.IsZero()
+	}
+}
+
+func compare(fv reflect.Value, rule, param string) string {
+	subject, isLength, ok := measure(fv)
+	if !ok {
+		panic(fmt.Sprintf("validate: rule %q is not applicable to %s", rule, fv.Type()))
+	}
+
+	limit, err := parseLimit(fv.Type(), param)
+	if err != nil {
+		panic(fmt.Sprintf("validate: invalid parameter %q for rule %q: %s", param, rule, err.Error()))
+	}
+
+	var pass bool
+	var phrase string
+	switch rule {
+	case "min", "gte":
+		pass, phrase = subject >= limit, "at least"
+	case "max", "lte":
+		pass, phrase = subject <= limit, "at most"
+	case "gt":
+		pass, phrase = subject > limit, "greater than"
+	case "lt":
+		pass, phrase = subject < limit, "less than"
+	}
+
+	if pass {
+		return ""
+	}
+
+	if isLength {
+		return fmt.Sprintf("length must be %s %s", phrase, param)
+	}
+
+	return fmt.Sprintf("must be %s %s", phrase, param)
+}
+
+// compareField compares fv to the field named param of parent.
+func compareField(parent, fv reflect.Value, rule, param string, name NameFunc) string {
+	sf, found := parent.Type().FieldByName(param)
+	if !found {
+		panic(fmt.Sprintf("validate: rule %q refers to the unknown field %q", rule, param))
+	}
+
+	subject, isLength, ok := measure(fv)
+	other, _, otherOK := measure(parent.FieldByIndex(sf.Index))
+	if !ok || !otherOK || isLength || fv.Type() != sf.Type {
+		panic(fmt.Sprintf("validate: rule %q is not applicable to %s and %s", rule, fv.Type(), sf.Type))
+	}
+
+	var pass bool
+	var phrase string
+	switch rule {
+	case "gtefield":
+		pass, phrase = subject >= other, "at least"
+	case "ltefield":
+		pass, phrase = subject <= other, "at most"
+	case "gtfield":
+		pass, phrase = subject > other, "greater than"
+	case "ltfield":
+		pass, phrase = subject < other, "less than"
+	}
+
+	if pass {
+		return ""
+	}
+
+	return fmt.Sprintf("must be %s %s (%v)", phrase, name(sf), parent.FieldByIndex(sf.Index))
+}
+
+// measure returns the value that comparison rules are applied to.
+func measure(fv reflect.Value) (float64, bool, bool) {
+	switch fv.Kind() { //nolint:exhaustive
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), false, true
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(fv.Len()), true, true
+	default:
+		return 0, false, false
+	}
+}
+
+func parseLimit(t reflect.Type, param string) (float64, error) {
+	if t == durationType {
+		d, err := time.ParseDuration(param)
+		return float64(d), err
+	}
+
+	return strconv.ParseFloat(param, 64)
+}
@@ -0,0 +1,72 @@
+package validate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type inner struct {
+	Timeout time.Duration `json:"timeout" validate:"gt=0"`
+}
+
+type embedded struct {
+	Level string `json:"level" validate:"oneof=debug info"`
+}
+
+type subject struct {
+	embedded
+
+	Name   string   `json:"name"   validate:"required"`
+	Type   string   `json:"type"   validate:"oneof=json text"`
+	Port   int      `json:"port"   validate:"min=1,max=65535"`
+	Tags   []string `json:"tags"   validate:"max=2"`
+	Min    int      `json:"min"`
+	Max    int      `json:"max"    validate:"gtefield=Min"`
+	Ignore string   `json:"-"      validate:"required"`
+	Inner  inner    `json:"inner"`
+}
+
+func TestStruct(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input    subject
+		expected Errors
+	}{
+		"valid": {
+			input: subject{embedded: embedded{Level: "info"}, Name: "a", Type: "json", Port: 80, Min: 1, Max: 1, Inner: inner{Timeout: time.Second}},
+		},
+		"all invalid": {
+			input: subject{Type: "xml", Port: 70000, Tags: []string{"a", "b", "c"}, Min: 2, Max: 1},
+			expected: Errors{
+				{Path: "level", Rule: "oneof", Message: `must be one of [debug info], got ""`},
+				{Path: "name", Rule: "required", Message: "is required"},
+				{Path: "type", Rule: "oneof", Message: `must be one of [json text], got "xml"`},
+				{Path: "port", Rule: "max", Message: "must be at most 65535"},
+				{Path: "tags", Rule: "max", Message: "length must be at most 2"},
+				{Path: "max", Rule: "gtefield", Message: "must be at least min (2)"},
+				{Path: "inner.timeout", Rule: "gt", Message: "must be greater than 0"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got := Struct(&tc.input, TagName("json"), "", ".")
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestStructUnknownRule(t *testing.T) {
+	t.Parallel()
+
+	v := struct {
+		A string `validate:"nope"`
+	}{}
+
+	assert.Panics(t, func() { Struct(v, TagName("json"), "", ".") })
+}
@@ -0,0 +1,285 @@
+package zhttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The names of the authenticators, as referenced by the routes (see Authenticators.Require) and documented as
+// OpenAPI security schemes.
+const (
+	AuthBasic  = "basic"
+	AuthAPIKey = "api_key"
+	AuthJWT    = "jwt"
+)
+
+type AuthConfig struct {
+	Basic  BasicAuthConfig  `koanf:"basic"`
+	APIKey APIKeyAuthConfig `koanf:"api_key"`
+	JWT    JWTAuthConfig    `koanf:"jwt"`
+}
+
+type BasicAuthConfig struct {
+	Enabled      bool   `koanf:"enabled"       default:"false"                             desc:"Accepts HTTP Basic credentials, verified against htpasswd_file."`
+	HtpasswdFile string `koanf:"htpasswd_file"                                             desc:"The htpasswd file of the users, with bcrypt hashes (htpasswd -B)."`
+	Realm        string `koanf:"realm"         default:"goboilerplate" validate:"required" desc:"The realm of the WWW-Authenticate challenge."`
+}
+
+type APIKeyAuthConfig struct {
+	Enabled  bool   `koanf:"enabled"   default:"false"                         desc:"Accepts static API keys."`
+	Header   string `koanf:"header"    default:"X-API-Key" validate:"required" desc:"The request header that carries the API key."`
+	KeysFile string `koanf:"keys_file"                                         desc:"The file of the API keys, one name:key line each; name is the authenticated principal."`
+}
+
+// SecuritySchemes returns the OpenAPI security schemes of every authenticator, by name. They are documented
+// whether the authenticators are enabled or not, so the document does not depend on the deployment.
+func (c AuthConfig) SecuritySchemes() map[string]SecurityScheme {
+	return map[string]SecurityScheme{
+		AuthBasic:  {Type: "http", Scheme: "basic"},
+		AuthAPIKey: {Type: "apiKey", In: "header", Name: c.APIKey.Header},
+		AuthJWT:    {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+	}
+}
+
+// Principal is the authenticated identity of a request.
+type Principal struct {
+	Subject       string         `json:"subject"`
+	Authenticator string         `json:"authenticator"` // the name of the authenticator, e.g. basic.
+	Roles         []string       `json:"roles,omitempty"`
+	Scopes        []string       `json:"scopes,omitempty"`
+	Claims        map[string]any `json:"claims,omitempty"` // the JWT claims.
+}
+
+type ctxPrincipalKey struct{}
+
+// PrincipalFromContext returns the authenticated principal of the request, if any.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, found := ctx.Value(ctxPrincipalKey{}).(Principal)
+	return p, found
+}
+
+// errNoCredentials is returned by the authenticators when the request carries none of their credentials,
+// so the next accepted authenticator is tried.
+var errNoCredentials = errors.New("no credentials")
+
+// Authenticator authenticates requests by a single kind of credentials.
+type Authenticator interface {
+	// Authenticate returns the principal of the credentials of r, or errNoCredentials when r carries none.
+	Authenticate(r *http.Request) (Principal, error)
+	// Challenge returns the WWW-Authenticate challenge of the authenticator, if any.
+	Challenge() string
+}
+
+// Authenticators are the enabled authenticators, by name.
+type Authenticators map[string]Authenticator
+
+// NewAuthenticators loads the enabled authenticators of c.
+func NewAuthenticators(c AuthConfig) (Authenticators, error) {
+	auths := Authenticators{}
+
+	if c.Basic.Enabled {
+		a, err := NewBasicAuthenticator(c.Basic)
+		if err != nil {
+			return nil, fmt.Errorf("http.auth.basic: %w", err)
+		}
+		auths[AuthBasic] = a
+	}
+
+	if c.APIKey.Enabled {
+		a, err := NewAPIKeyAuthenticator(c.APIKey)
+		if err != nil {
+			return nil, fmt.Errorf("http.auth.api_key: %w", err)
+		}
+		auths[AuthAPIKey] = a
+	}
+
+	if c.JWT.Enabled {
+		a, err := NewJWTAuthenticator(c.JWT)
+		if err != nil {
+			return nil, fmt.Errorf("http.auth.jwt: %w", err)
+		}
+		auths[AuthJWT] = a
+	}
+
+	return auths, nil
+}
+
+// Require returns a middleware that accepts requests authenticated by any of the named authenticators, tried in
+// order; disabled ones are skipped. The principal is placed in the request context (see PrincipalFromContext) and
+// in the attributes of its logger. Requests without credentials, or with invalid ones, get a 401 problem along
+// with the challenges of the authenticators.
+func (a Authenticators) Require(names ...string) func(http.Handler) http.Handler {
+	accepted := make([]Authenticator, 0, len(names))
+	challenges := []string{}
+	for _, n := range names {
+		if au, found := a[n]; found {
+			accepted = append(accepted, au)
+			if c := au.Challenge(); c != "" {
+				challenges = append(challenges, c)
+			}
+		}
+	}
+
+	unauthorized := func(w http.ResponseWriter, r *http.Request, detail string) {
+		for _, c := range challenges {
+			w.Header().Add("WWW-Authenticate", c)
+		}
+		RespondError(r.Context(), w, NewAPIError(http.StatusUnauthorized, detail))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			for _, au := range accepted {
+				p, err := au.Authenticate(r)
+				if errors.Is(err, errNoCredentials) {
+					continue
+				}
+				if err != nil {
+					// at debug level, as any client can cause them.
+					zlog.GetFromContext(ctx).DebugContext(ctx, "authentication failed", zlog.Error(err))
+					unauthorized(w, r, "The credentials are invalid.")
+					return
+				}
+
+				logger := zlog.GetFromContext(ctx).With(slog.String("principal", p.Subject), slog.String("authenticator", p.Authenticator))
+				ctx = zlog.SetInContext(context.WithValue(ctx, ctxPrincipalKey{}, p), logger)
+				logger.DebugContext(ctx, "request authenticated")
+
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			unauthorized(w, r, "Authentication is required.")
+		})
+	}
+}
+
+// BasicAuthenticator verifies HTTP Basic credentials against the bcrypt hashes of an htpasswd file.
+type BasicAuthenticator struct {
+	realm  string
+	hashes map[string][]byte
+	dummy  []byte // compared for unknown users, so they take as long as known ones.
+}
+
+func NewBasicAuthenticator(c BasicAuthConfig) (*BasicAuthenticator, error) {
+	hashes := map[string][]byte{}
+	err := readCredentialsFile(c.HtpasswdFile, func(user, hash string) error {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("the hash of user %q is not bcrypt: %w", user, err)
+		}
+		hashes[user] = []byte(hash)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dummy, err := bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BasicAuthenticator{realm: c.Realm, hashes: hashes, dummy: dummy}, nil
+}
+
+func (a *BasicAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return Principal{}, errNoCredentials
+	}
+
+	hash, found := a.hashes[user]
+	if !found {
+		_ = bcrypt.CompareHashAndPassword(a.dummy, []byte(pass))
+		return Principal{}, errors.New("unknown user")
+	}
+
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(pass)); err != nil {
+		return Principal{}, errors.New("wrong password")
+	}
+
+	return Principal{Subject: user, Authenticator: AuthBasic}, nil
+}
+
+func (a *BasicAuthenticator) Challenge() string {
+	return `Basic realm="` + strings.ReplaceAll(a.realm, `"`, `'`) + `", charset="UTF-8"`
+}
+
+// APIKeyAuthenticator authenticates the static API keys of a request header.
+type APIKeyAuthenticator struct {
+	header string
+	names  map[[sha256.Size]byte]string // by the sha256 digest of the key; the lookup time depends on the digest only, never on the key.
+}
+
+func NewAPIKeyAuthenticator(c APIKeyAuthConfig) (*APIKeyAuthenticator, error) {
+	names := map[[sha256.Size]byte]string{}
+	err := readCredentialsFile(c.KeysFile, func(name, key string) error {
+		names[sha256.Sum256([]byte(key))] = name
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &APIKeyAuthenticator{header: c.Header, names: names}, nil
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	key := r.Header.Get(a.header)
+	if key == "" {
+		return Principal{}, errNoCredentials
+	}
+
+	name, found := a.names[sha256.Sum256([]byte(key))]
+	if !found {
+		return Principal{}, errors.New("unknown api key")
+	}
+
+	return Principal{Subject: name, Authenticator: AuthAPIKey}, nil
+}
+
+func (a *APIKeyAuthenticator) Challenge() string { return "" }
+
+// readCredentialsFile calls fn with the name and the secret of every name:secret line of the file.
+// Empty lines and lines starting with # are skipped.
+func readCredentialsFile(file string, fn func(name, secret string) error) error {
+	if file == "" {
+		return errors.New("no credentials file is set")
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, secret, found := strings.Cut(line, ":")
+		if !found || name == "" || secret == "" {
+			return fmt.Errorf("%s:%d: not a name:secret line", file, n)
+		}
+		if err := fn(name, secret); err != nil {
+			return fmt.Errorf("%s:%d: %w", file, n, err)
+		}
+	}
+
+	return s.Err()
+}
@@ -0,0 +1,173 @@
+package zhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+
+	return file
+}
+
+func newTestAuthenticators(t *testing.T) Authenticators {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	auths, err := NewAuthenticators(AuthConfig{
+		Basic: BasicAuthConfig{
+			Enabled:      true,
+			HtpasswdFile: writeTestFile(t, "htpasswd", "# users\nyoda:"+string(hash)+"\n"),
+			Realm:        "test",
+		},
+		APIKey: APIKeyAuthConfig{
+			Enabled:  true,
+			Header:   "X-API-Key",
+			KeysFile: writeTestFile(t, "keys", "ci:key-1\n\nbot:key:2\n"),
+		},
+	})
+	require.NoError(t, err)
+
+	return auths
+}
+
+func TestAuthenticatorsRequire(t *testing.T) {
+	t.Parallel()
+
+	auths := newTestAuthenticators(t)
+	handler := func(names ...string) http.Handler {
+		return auths.Require(names...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, found := PrincipalFromContext(r.Context())
+			assert.True(t, found)
+			_ = json.NewEncoder(w).Encode(p)
+		}))
+	}
+
+	tests := map[string]struct {
+		names          []string
+		header         http.Header
+		expectedStatus int
+		expected       Principal
+		expectedAuth   []string
+	}{
+		"basic": {
+			names:          []string{AuthBasic, AuthAPIKey},
+			header:         http.Header{"Authorization": {"Basic eW9kYTpzZWNyZXQ="}}, // yoda:secret
+			expectedStatus: http.StatusOK,
+			expected:       Principal{Subject: "yoda", Authenticator: AuthBasic},
+		},
+		"api key": {
+			names:          []string{AuthBasic, AuthAPIKey},
+			header:         http.Header{"X-Api-Key": {"key:2"}},
+			expectedStatus: http.StatusOK,
+			expected:       Principal{Subject: "bot", Authenticator: AuthAPIKey},
+		},
+		"wrong password": {
+			names:          []string{AuthBasic, AuthAPIKey},
+			header:         http.Header{"Authorization": {"Basic eW9kYTp3cm9uZw=="}}, // yoda:wrong
+			expectedStatus: http.StatusUnauthorized,
+			expectedAuth:   []string{`Basic realm="test", charset="UTF-8"`},
+		},
+		"unknown user": {
+			names:          []string{AuthBasic},
+			header:         http.Header{"Authorization": {"Basic b2JpOnNlY3JldA=="}}, // obi:secret
+			expectedStatus: http.StatusUnauthorized,
+			expectedAuth:   []string{`Basic realm="test", charset="UTF-8"`},
+		},
+		"unknown api key": {
+			names:          []string{AuthAPIKey},
+			header:         http.Header{"X-Api-Key": {"key-2"}},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"no credentials": {
+			names:          []string{AuthBasic, AuthAPIKey},
+			expectedStatus: http.StatusUnauthorized,
+			expectedAuth:   []string{`Basic realm="test", charset="UTF-8"`},
+		},
+		"credentials of an authenticator not accepted": {
+			names:          []string{AuthAPIKey},
+			header:         http.Header{"Authorization": {"Basic eW9kYTpzZWNyZXQ="}},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"disabled authenticator": {
+			names:          []string{AuthJWT},
+			header:         http.Header{"Authorization": {"Bearer a.b.c"}},
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			logs := &bytes.Buffer{}
+			ctx := zlog.SetInContext(t.Context(), slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+			req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+			resp := httptest.NewRecorder()
+			handler(tc.names...).ServeHTTP(resp, req)
+
+			require.Equal(t, tc.expectedStatus, resp.Code)
+			if tc.expectedStatus != http.StatusOK {
+				assert.Equal(t, ProblemContentType, resp.Header().Get("Content-Type"))
+				assert.Equal(t, tc.expectedAuth, resp.Header().Values("WWW-Authenticate"))
+
+				// failures are logged at debug level, without the attempted user.
+				for line := range bytes.Lines(logs.Bytes()) {
+					assert.Contains(t, string(line), `"level":"DEBUG"`)
+				}
+				assert.NotContains(t, logs.String(), "yoda")
+				assert.NotContains(t, logs.String(), "obi")
+				return
+			}
+
+			got := Principal{}
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestNewAuthenticatorsErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]AuthConfig{
+		"no htpasswd file":   {Basic: BasicAuthConfig{Enabled: true}},
+		"missing file":       {Basic: BasicAuthConfig{Enabled: true, HtpasswdFile: filepath.Join(t.TempDir(), "missing")}},
+		"not bcrypt":         {Basic: BasicAuthConfig{Enabled: true, HtpasswdFile: writeTestFile(t, "htpasswd", "yoda:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n")}},
+		"malformed key line": {APIKey: APIKeyAuthConfig{Enabled: true, Header: "X-API-Key", KeysFile: writeTestFile(t, "keys", "key-without-name\n")}},
+		"no jwks file":       {JWT: JWTAuthConfig{Enabled: true}},
+	}
+
+	for name, c := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewAuthenticators(c)
+			assert.Error(t, err)
+		})
+	}
+
+	auths, err := NewAuthenticators(AuthConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, auths)
+}
@@ -0,0 +1,143 @@
+package zhttp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+)
+
+type AuthzConfig struct {
+	SubjectRoles map[string]map[string][]string `koanf:"subject_roles" desc:"The roles granted to principals, by authenticator and subject (e.g. basic: {yoda: [admin]}), in addition to the roles of their credentials."`
+	RoleScopes   map[string][]string            `koanf:"role_scopes"   desc:"The scopes granted by each role (e.g. admin: [echo:read, echo:write])."`
+}
+
+// Requirement is what a route requires of the principal: every one of the roles and every one of the scopes.
+type Requirement struct {
+	Roles  []string
+	Scopes []string
+}
+
+// Decision is the outcome of the evaluation of a Requirement for a principal.
+type Decision struct {
+	Allowed bool
+	Roles   []string // the effective roles of the principal.
+	Scopes  []string // the effective scopes of the principal.
+	Missing []string // the unmet requirements, e.g. role:admin or scope:echo:write.
+}
+
+// Policy grants roles and scopes to principals, on top of the ones of their credentials (e.g. the JWT claims), and
+// decides whether they meet the requirements of the routes. It is loaded from config and involves no HTTP.
+type Policy struct {
+	subjectRoles map[string]map[string][]string
+	roleScopes   map[string][]string
+}
+
+func NewPolicy(c AuthzConfig) *Policy {
+	return &Policy{subjectRoles: c.SubjectRoles, roleScopes: c.RoleScopes}
+}
+
+// Evaluate decides whether p meets req.
+func (pl *Policy) Evaluate(p Principal, req Requirement) Decision {
+	roles := union(p.Roles, pl.subjectRoles[p.Authenticator][p.Subject])
+
+	scopes := slices.Clone(p.Scopes)
+	for _, r := range roles {
+		scopes = union(scopes, pl.roleScopes[r])
+	}
+
+	d := Decision{Roles: roles, Scopes: scopes}
+	for _, r := range req.Roles {
+		if !slices.Contains(roles, r) {
+			d.Missing = append(d.Missing, "role:"+r)
+		}
+	}
+	for _, s := range req.Scopes {
+		if !slices.Contains(scopes, s) {
+			d.Missing = append(d.Missing, "scope:"+s)
+		}
+	}
+	d.Allowed = len(d.Missing) == 0
+
+	return d
+}
+
+// union returns a with the elements of b that are not in it.
+func union(a, b []string) []string {
+	out := slices.Clone(a)
+	for _, e := range b {
+		if !slices.Contains(out, e) {
+			out = append(out, e)
+		}
+	}
+
+	return out
+}
+
+// errForbidden is the cause of the 403 responses; the unmet requirements are logged, never rendered.
+var errForbidden = errors.New("forbidden")
+
+// Require returns a middleware that lets through the requests whose principal (see Authenticators.Require) meets
+// req, and responds to the rest with a 403 problem (401 when the request is not authenticated). Every decision is
+// written to the audit log (see zlog.Audit), through the request logger which carries the principal, whatever the
+// log level.
+func (pl *Policy) Require(req Requirement) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			p, authenticated := PrincipalFromContext(ctx)
+			if !authenticated {
+				audit(ctx, r, req, Decision{}, "not authenticated")
+				RespondError(ctx, w, NewAPIError(http.StatusUnauthorized, "Authentication is required."))
+				return
+			}
+
+			d := pl.Evaluate(p, req)
+			if !d.Allowed {
+				audit(ctx, r, req, d, "missing "+strings.Join(d.Missing, ", "))
+				RespondError(ctx, w, &APIError{
+					Status: http.StatusForbidden,
+					Detail: "The principal is not allowed to access the resource.",
+					Err:    errForbidden,
+				})
+				return
+			}
+
+			audit(ctx, r, req, d, "")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// audit writes the authorization decision of r to the audit log; denials at warn level.
+func audit(ctx context.Context, r *http.Request, req Requirement, d Decision, reason string) {
+	level, msg := slog.LevelInfo, "access granted"
+	if reason != "" {
+		level, msg = slog.LevelWarn, "access denied"
+	}
+
+	route := r.URL.Path
+	if rc := chi.RouteContext(ctx); rc != nil && rc.RoutePattern() != "" {
+		route = rc.RoutePattern()
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("route", route),
+		slog.Any("required_roles", req.Roles),
+		slog.Any("required_scopes", req.Scopes),
+		slog.Any("roles", d.Roles),
+		slog.Any("scopes", d.Scopes),
+	}
+	if reason != "" {
+		attrs = append(attrs, slog.String("reason", reason))
+	}
+
+	zlog.Audit(ctx).LogAttrs(ctx, level, msg, attrs...)
+}
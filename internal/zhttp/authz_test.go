@@ -0,0 +1,193 @@
+package zhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPolicy() *Policy {
+	return NewPolicy(AuthzConfig{
+		SubjectRoles: map[string]map[string][]string{
+			AuthBasic:  {"yoda": {"admin"}},
+			AuthAPIKey: {"ci": {"deployer"}},
+		},
+		RoleScopes: map[string][]string{
+			"admin":    {"echo", "items:write"},
+			"deployer": {"deploy"},
+		},
+	})
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	t.Parallel()
+
+	policy := newTestPolicy()
+
+	tests := map[string]struct {
+		principal Principal
+		req       Requirement
+		expected  Decision
+	}{
+		"no requirement": {
+			principal: Principal{Subject: "obi", Authenticator: AuthBasic},
+			expected:  Decision{Allowed: true},
+		},
+		"roles and scopes of the policy": {
+			principal: Principal{Subject: "yoda", Authenticator: AuthBasic},
+			req:       Requirement{Roles: []string{"admin"}, Scopes: []string{"echo"}},
+			expected:  Decision{Allowed: true, Roles: []string{"admin"}, Scopes: []string{"echo", "items:write"}},
+		},
+		"roles are granted by authenticator": {
+			principal: Principal{Subject: "yoda", Authenticator: AuthJWT},
+			req:       Requirement{Roles: []string{"admin"}},
+			expected:  Decision{Missing: []string{"role:admin"}},
+		},
+		"roles and scopes of the credentials": {
+			principal: Principal{Subject: "luke", Authenticator: AuthJWT, Roles: []string{"deployer"}, Scopes: []string{"read"}},
+			req:       Requirement{Scopes: []string{"read", "deploy"}},
+			expected:  Decision{Allowed: true, Roles: []string{"deployer"}, Scopes: []string{"read", "deploy"}},
+		},
+		"every requirement is needed": {
+			principal: Principal{Subject: "ci", Authenticator: AuthAPIKey},
+			req:       Requirement{Roles: []string{"deployer", "admin"}, Scopes: []string{"deploy", "echo"}},
+			expected:  Decision{Roles: []string{"deployer"}, Scopes: []string{"deploy"}, Missing: []string{"role:admin", "scope:echo"}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, policy.Evaluate(tc.principal, tc.req))
+		})
+	}
+
+	// an empty policy grants only the roles and scopes of the credentials.
+	d := NewPolicy(AuthzConfig{}).Evaluate(Principal{Subject: "yoda", Authenticator: AuthBasic, Scopes: []string{"echo"}}, Requirement{Scopes: []string{"echo"}})
+	assert.True(t, d.Allowed)
+}
+
+func TestPolicyRequire(t *testing.T) {
+	t.Parallel()
+
+	policy := newTestPolicy()
+	router := chi.NewRouter()
+	router.With(policy.Require(Requirement{Scopes: []string{"items:write"}})).Post("/items/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	do := func(t *testing.T, p *Principal, level slog.Level) (*httptest.ResponseRecorder, []map[string]any) {
+		t.Helper()
+
+		logs := &bytes.Buffer{}
+		ctx := zlog.SetInContext(t.Context(), slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: level})))
+		if p != nil {
+			ctx = context.WithValue(ctx, ctxPrincipalKey{}, *p)
+		}
+
+		req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/items/7", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		records := []map[string]any{}
+		for line := range bytes.Lines(logs.Bytes()) {
+			record := map[string]any{}
+			require.NoError(t, json.Unmarshal(line, &record))
+			if record[zlog.AuditKey] == true {
+				records = append(records, record)
+			}
+		}
+
+		return resp, records
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		t.Parallel()
+
+		resp, records := do(t, &Principal{Subject: "yoda", Authenticator: AuthBasic}, slog.LevelInfo)
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		require.Len(t, records, 1)
+		assert.Equal(t, "access granted", records[0]["msg"])
+		assert.Equal(t, "/items/{id}", records[0]["route"])
+		assert.Equal(t, http.MethodPost, records[0]["method"])
+	})
+
+	t.Run("allowed at warn level", func(t *testing.T) {
+		t.Parallel()
+
+		// the grants are audited even when the info logs are off.
+		resp, records := do(t, &Principal{Subject: "yoda", Authenticator: AuthBasic}, slog.LevelWarn)
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		require.Len(t, records, 1)
+		assert.Equal(t, "access granted", records[0]["msg"])
+		assert.Equal(t, "INFO", records[0]["level"])
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		t.Parallel()
+
+		resp, records := do(t, &Principal{Subject: "ci", Authenticator: AuthAPIKey}, slog.LevelInfo)
+		require.Equal(t, http.StatusForbidden, resp.Code)
+		assert.Equal(t, ProblemContentType, resp.Header().Get("Content-Type"))
+		problem := APIError{}
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &problem))
+		assert.Equal(t, "The principal is not allowed to access the resource.", problem.Detail)
+		assert.NotContains(t, resp.Body.String(), "items:write")
+
+		require.Len(t, records, 1)
+		assert.Equal(t, "access denied", records[0]["msg"])
+		assert.Equal(t, "WARN", records[0]["level"])
+		assert.Equal(t, "missing scope:items:write", records[0]["reason"])
+	})
+
+	t.Run("not authenticated", func(t *testing.T) {
+		t.Parallel()
+
+		resp, records := do(t, nil, slog.LevelInfo)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		require.Len(t, records, 1)
+		assert.Equal(t, "not authenticated", records[0]["reason"])
+	})
+}
+
+func TestAPIAuthorize(t *testing.T) {
+	t.Parallel()
+
+	router := chi.NewRouter()
+	api := NewAPI(router, OpenAPIInfo{Title: "test", Version: "1.0.0"})
+	api.Group(func(api *API) {
+		api.Authenticate(newTestAuthenticators(t), AuthBasic, AuthAPIKey)
+		api.Authorize(newTestPolicy(), Requirement{Roles: []string{"admin"}})
+		api.Group(func(api *API) {
+			api.Authorize(newTestPolicy(), Requirement{Scopes: []string{"echo"}})
+			api.Method(http.MethodGet, "/echo", http.NotFoundHandler())
+		})
+	})
+
+	op := api.OpenAPI().Paths["/echo"]["get"]
+	require.NotNil(t, op)
+	assert.Equal(t, []map[string][]string{{AuthBasic: {"admin", "echo"}}, {AuthAPIKey: {"admin", "echo"}}}, op.Security)
+
+	// the api key of ci authenticates, but ci is not an admin.
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/echo", nil)
+	req.Header.Set("X-API-Key", "key-1")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	req = httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/echo", nil)
+	req.SetBasicAuth("yoda", "secret")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
@@ -0,0 +1,200 @@
+package zhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.yaml.in/yaml/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec encodes response bodies to, and decodes request bodies from, a single media type.
+// Codecs are selected by Respond (on the Accept header) and Decode (on the Content-Type header).
+type Codec interface {
+	MediaType() string // e.g. application/json
+	Encode(w io.Writer, v any) error
+	Decode(r io.Reader, v any) error
+}
+
+// ValueCodec is implemented by codecs that can encode only some values (e.g. protobuf encodes only proto messages).
+// Negotiation skips them for the values they do not support.
+type ValueCodec interface {
+	Codec
+	Supports(v any) bool
+}
+
+// ErrUnsupportedValue is returned by codecs for values they cannot encode or decode.
+var ErrUnsupportedValue = errors.New("unsupported value")
+
+//nolint:gochecknoglobals
+var (
+	codecsMu sync.RWMutex
+	codecs   = []Codec{JSONCodec{}, YAMLCodec{}, MessagePackCodec{}, CBORCodec{}, ProtobufCodec{}}
+)
+
+// RegisterCodec registers c, replacing any codec of the same media type.
+// On equally preferred media types, the codec registered first wins (JSON is the first of the defaults).
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	for i, existing := range codecs {
+		if existing.MediaType() == c.MediaType() {
+			codecs[i] = c
+			return
+		}
+	}
+	codecs = append(codecs, c)
+}
+
+func registeredCodecs() []Codec {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	return append([]Codec(nil), codecs...)
+}
+
+// JSONCodec is the application/json codec.
+type JSONCodec struct{}
+
+func (JSONCodec) MediaType() string { return "application/json" }
+
+func (JSONCodec) Encode(w io.Writer, v any) error { return json.NewEncoder(w).Encode(v) }
+
+func (JSONCodec) Decode(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("unexpected data after the json value")
+	}
+
+	return nil
+}
+
+// YAMLCodec is the application/yaml codec. Values go through their json form,
+// so json struct tags and json (un)marshalers apply as they do to JSONCodec.
+type YAMLCodec struct{}
+
+func (YAMLCodec) MediaType() string { return "application/yaml" }
+
+func (YAMLCodec) Encode(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	// json is valid yaml; decoding it to a node keeps the exact scalars (e.g. large integers).
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return err
+	}
+	blockStyle(&node)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+func (YAMLCodec) Decode(r io.Reader, v any) error {
+	var doc any
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("yaml document is not representable as json: %w", err)
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// blockStyle turns the flow style of the nodes decoded from json into the yaml block style.
+func blockStyle(n *yaml.Node) {
+	n.Style &^= yaml.FlowStyle
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!str" && n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		n.Style &^= yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle // the encoder quotes strings only where needed.
+	}
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+// MessagePackCodec is the application/msgpack codec. It uses the json struct tags.
+type MessagePackCodec struct{}
+
+func (MessagePackCodec) MediaType() string { return "application/msgpack" }
+
+func (MessagePackCodec) Encode(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+
+	return enc.Encode(v)
+}
+
+func (MessagePackCodec) Decode(r io.Reader, v any) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+
+	return dec.Decode(v)
+}
+
+// CBORCodec is the application/cbor codec (RFC 8949). It falls back to the json struct tags.
+type CBORCodec struct{}
+
+func (CBORCodec) MediaType() string { return "application/cbor" }
+
+func (CBORCodec) Encode(w io.Writer, v any) error { return cbor.NewEncoder(w).Encode(v) }
+
+func (CBORCodec) Decode(r io.Reader, v any) error { return cbor.NewDecoder(r).Decode(v) }
+
+// ProtobufCodec is the application/protobuf codec. It supports only proto messages.
+type ProtobufCodec struct{}
+
+func (ProtobufCodec) MediaType() string { return "application/protobuf" }
+
+func (ProtobufCodec) Supports(v any) bool {
+	_, is := v.(proto.Message)
+	return is
+}
+
+func (ProtobufCodec) Encode(w io.Writer, v any) error {
+	m, is := v.(proto.Message)
+	if !is {
+		return fmt.Errorf("%w: %T is not a proto message", ErrUnsupportedValue, v)
+	}
+
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+
+	return err
+}
+
+func (ProtobufCodec) Decode(r io.Reader, v any) error {
+	m, is := v.(proto.Message)
+	if !is {
+		return fmt.Errorf("%w: %T is not a proto message", ErrUnsupportedValue, v)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(b, m)
+}
@@ -0,0 +1,69 @@
+package zhttp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type codecTestBody struct {
+	Name    string   `json:"name"`
+	Count   int64    `json:"count"`
+	Enabled bool     `json:"enabled"`
+	Tags    []string `json:"tags,omitempty"`
+	Flag    string   `json:"flag"`
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	in := codecTestBody{Name: "a: b", Count: 1 << 60, Enabled: true, Tags: []string{"x", "y"}, Flag: "true"}
+
+	for _, c := range []Codec{JSONCodec{}, YAMLCodec{}, MessagePackCodec{}, CBORCodec{}} {
+		t.Run(c.MediaType(), func(t *testing.T) {
+			t.Parallel()
+
+			buf := &bytes.Buffer{}
+			require.NoError(t, c.Encode(buf, in))
+
+			var out codecTestBody
+			require.NoError(t, c.Decode(buf, &out))
+			assert.Equal(t, in, out)
+		})
+	}
+}
+
+func TestYAMLCodecEncode(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, YAMLCodec{}.Encode(buf, codecTestBody{Name: "a: b", Count: 1 << 60, Tags: []string{"x"}, Flag: "true"}))
+
+	expected := `name: 'a: b'
+count: 1152921504606846976
+enabled: false
+tags:
+  - x
+flag: "true"
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestProtobufCodec(t *testing.T) {
+	t.Parallel()
+
+	c := ProtobufCodec{}
+	assert.False(t, c.Supports(codecTestBody{}))
+	require.ErrorIs(t, c.Encode(&bytes.Buffer{}, codecTestBody{}), ErrUnsupportedValue)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, c.Encode(buf, wrapperspb.String("hello")))
+
+	out := &wrapperspb.StringValue{}
+	require.NoError(t, c.Decode(buf, out))
+	assert.True(t, proto.Equal(wrapperspb.String("hello"), out))
+}
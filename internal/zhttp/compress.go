@@ -0,0 +1,467 @@
+package zhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+)
+
+// Content codings supported by Compress and Decompress.
+const (
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+	EncodingZstd   = "zstd"
+)
+
+// CompressionConfig is the config of the Compress and Decompress middlewares.
+type CompressionConfig struct {
+	Enabled              bool     `koanf:"enabled"                default:"true"                                                                                                                                                         desc:"Compresses responses with the encoding preferred by the Accept-Encoding header."`
+	Encodings            []string `koanf:"encodings"              default:"zstd,br,gzip"                                                                                                           validate:"required"                   desc:"The response encodings (zstd, br, gzip), in order of preference among the ones the client accepts with the same quality."`
+	Level                string   `koanf:"level"                  default:"default"                                                                                                                validate:"oneof=fastest default best" desc:"The compression level of every encoding."`
+	MinSize              int      `koanf:"min_size"               default:"1024"                                                                                                                   validate:"min=0"                      desc:"Responses smaller than this (in bytes) are sent uncompressed."`
+	ContentTypes         []string `koanf:"content_types"          default:"application/json,application/problem+json,application/yaml,application/xml,application/javascript,image/svg+xml,text/*"                                       desc:"The media types (type/* wildcards allowed) of the responses that are compressed."`
+	DecompressRequests   bool     `koanf:"decompress_requests"    default:"true"                                                                                                                                                         desc:"Decompresses request bodies according to their Content-Encoding (gzip, br, zstd); other encodings are refused with 415."`
+	MaxDecompressedBytes int64    `koanf:"max_decompressed_bytes" default:"10485760"                                                                                                               validate:"min=0"                      desc:"The maximum decompressed size of a request body, which guards against decompression bombs. 0 means no limit."`
+}
+
+// encoder is implemented by the writers of every supported encoding.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+func newEncoderPool(encoding, level string) (*sync.Pool, error) {
+	var newEncoder func() encoder
+	switch encoding {
+	case EncodingGzip:
+		l := gzip.DefaultCompression
+		switch level {
+		case "fastest":
+			l = gzip.BestSpeed
+		case "best":
+			l = gzip.BestCompression
+		}
+		newEncoder = func() encoder {
+			e, _ := gzip.NewWriterLevel(io.Discard, l) // the level is valid.
+			return e
+		}
+	case EncodingBrotli:
+		l := brotli.DefaultCompression
+		switch level {
+		case "fastest":
+			l = brotli.BestSpeed
+		case "best":
+			l = brotli.BestCompression
+		}
+		newEncoder = func() encoder { return brotli.NewWriterLevel(io.Discard, l) }
+	case EncodingZstd:
+		l := zstd.SpeedDefault
+		switch level {
+		case "fastest":
+			l = zstd.SpeedFastest
+		case "best":
+			l = zstd.SpeedBestCompression
+		}
+		newEncoder = func() encoder {
+			e, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(l), zstd.WithEncoderConcurrency(1)) // the options are valid.
+			return e
+		}
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+
+	return &sync.Pool{New: func() any { return newEncoder() }}, nil
+}
+
+type compressor struct {
+	encodings    []string
+	pools        map[string]*sync.Pool
+	minSize      int
+	contentTypes []string
+}
+
+func (c *compressor) get(encoding string, w io.Writer) encoder {
+	e, _ := c.pools[encoding].Get().(encoder)
+	e.Reset(w)
+	return e
+}
+
+func (c *compressor) put(encoding string, e encoder) {
+	e.Reset(io.Discard)
+	c.pools[encoding].Put(e)
+}
+
+// allowed reports whether responses of contentType are compressed.
+func (c *compressor) allowed(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range c.contentTypes {
+		if prefix, isWildcard := strings.CutSuffix(pattern, "/*"); isWildcard {
+			if strings.HasPrefix(mt, prefix+"/") {
+				return true
+			}
+		} else if mt == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Compress compresses the responses with the encoding (among c.Encodings) preferred by the Accept-Encoding header.
+// Only responses of c.ContentTypes that reach c.MinSize bytes (or are flushed) are compressed, and those media types
+// are always sent with "Vary: Accept-Encoding". Unknown encodings of c.Encodings are ignored with a warning.
+func Compress(ctx context.Context, c CompressionConfig) func(http.Handler) http.Handler {
+	cmp := &compressor{
+		pools:        map[string]*sync.Pool{},
+		minSize:      c.MinSize,
+		contentTypes: c.ContentTypes,
+	}
+
+	for _, e := range c.Encodings {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if _, exists := cmp.pools[e]; exists {
+			continue
+		}
+		pool, err := newEncoderPool(e, c.Level)
+		if err != nil {
+			zlog.GetFromContext(ctx).WarnContext(ctx, "compression encoding ignored", zlog.Error(err))
+			continue
+		}
+		cmp.pools[e] = pool
+		cmp.encodings = append(cmp.encodings, e)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Connection") == "Upgrade" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				c:              cmp,
+				encoding:       acceptedEncoding(r.Header.Values("Accept-Encoding"), cmp.encodings),
+				head:           r.Method == http.MethodHead,
+			}
+
+			// not deferred: after a panic the buffered response must not be sent, so Recoverer can still respond.
+			next.ServeHTTP(cw, r)
+			cw.close()
+		})
+	}
+}
+
+// acceptedEncoding returns the one of encodings (in order of preference) with the highest quality in the
+// Accept-Encoding header values, or "" when none is acceptable (RFC 9110 12.5.3).
+func acceptedEncoding(header []string, encodings []string) string {
+	qualities := map[string]float64{}
+	for _, v := range header {
+		for part := range strings.SplitSeq(v, ",") {
+			coding, params, _ := strings.Cut(part, ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding == "" {
+				continue
+			}
+
+			q := 1.0
+			if k, qs, found := strings.Cut(params, "="); found && strings.TrimSpace(k) == "q" {
+				var err error
+				if q, err = strconv.ParseFloat(strings.TrimSpace(qs), 64); err != nil || q < 0 || q > 1 {
+					continue
+				}
+			}
+			qualities[coding] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, e := range encodings {
+		q, found := qualities[e]
+		if !found {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = e, q
+		}
+	}
+
+	return best
+}
+
+// compressWriter buffers the response until it either reaches the minimum size (then it is compressed)
+// or the handler returns (then it is sent as is).
+type compressWriter struct {
+	http.ResponseWriter
+	c        *compressor
+	encoding string // the negotiated encoding; empty when the client accepts none.
+	head     bool
+	status   int // 0 until WriteHeader.
+	buf      []byte
+	decided  bool
+	enc      encoder // not nil while compressing.
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.status != 0 {
+		return
+	}
+	cw.status = code
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.decided {
+		if cw.enc != nil {
+			return cw.enc.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.c.minSize {
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush sends the response so far; a flushed response is compressed regardless of its size.
+func (cw *compressWriter) Flush() {
+	_ = cw.FlushError()
+}
+
+func (cw *compressWriter) FlushError() error {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		if err := cw.decide(true); err != nil {
+			return err
+		}
+	}
+
+	if cw.enc != nil {
+		if err := cw.enc.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide writes the header, compressing the response if it is eligible and large is true, and then the buffered body.
+func (cw *compressWriter) decide(large bool) error {
+	cw.decided = true
+	h := cw.Header()
+
+	if _, hasType := h["Content-Type"]; !hasType && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	allowed := cw.c.allowed(h.Get("Content-Type"))
+	if allowed {
+		addVary(h, "Accept-Encoding")
+	}
+
+	compress := large && allowed && cw.encoding != "" && !cw.head &&
+		h.Get("Content-Encoding") == "" &&
+		cw.status != http.StatusNoContent && cw.status != http.StatusNotModified && cw.status != http.StatusPartialContent
+
+	// the compressed representation has a different (weak) entity tag; 304 responses carry the one of the representation the client has.
+	if compress || (allowed && cw.encoding != "" && cw.status == http.StatusNotModified) {
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+	}
+
+	if compress {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if compress {
+		cw.enc = cw.c.get(cw.encoding, cw.ResponseWriter)
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+
+	return err
+}
+
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		_ = cw.decide(false)
+	}
+
+	if cw.enc != nil {
+		_ = cw.enc.Close()
+		cw.c.put(cw.encoding, cw.enc)
+		cw.enc = nil
+	}
+}
+
+// addVary adds value to the Vary header, unless it is already there.
+func addVary(h http.Header, value string) {
+	for _, v := range h.Values("Vary") {
+		for field := range strings.SplitSeq(v, ",") {
+			if f := strings.TrimSpace(field); f == "*" || strings.EqualFold(f, value) {
+				return
+			}
+		}
+	}
+
+	h.Add("Vary", value)
+}
+
+// contentEncodings returns the (lower cased) content codings of the Content-Encoding header, identity excluded.
+func contentEncodings(h http.Header) []string {
+	var encodings []string
+	for _, v := range h.Values("Content-Encoding") {
+		for e := range strings.SplitSeq(v, ",") {
+			if e = strings.ToLower(strings.TrimSpace(e)); e != "" && e != "identity" {
+				encodings = append(encodings, e)
+			}
+		}
+	}
+
+	return encodings
+}
+
+// errUnsupportedEncoding is returned by newDecoder for unknown content codings.
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// decoder closes both the decompressing reader and the request body.
+type decoder struct {
+	io.Reader
+	close func()
+	body  io.Closer
+}
+
+func (d decoder) Close() error {
+	if d.close != nil {
+		d.close()
+	}
+	return d.body.Close()
+}
+
+func newDecoder(encoding string, body io.ReadCloser, maxBytes int64) (io.ReadCloser, error) {
+	switch encoding {
+	case EncodingGzip, "x-gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		return decoder{Reader: zr, body: body}, nil
+	case EncodingBrotli:
+		return decoder{Reader: brotli.NewReader(body), body: body}, nil
+	case EncodingZstd:
+		opts := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+		if maxBytes > 0 {
+			opts = append(opts, zstd.WithDecoderMaxMemory(uint64(maxBytes)))
+		}
+		zr, err := zstd.NewReader(body, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return decoder{Reader: zr, close: zr.Close, body: body}, nil
+	default:
+		return nil, fmt.Errorf("%w %q", errUnsupportedEncoding, encoding)
+	}
+}
+
+// Decompress decompresses the request bodies according to their Content-Encoding (gzip, br or zstd).
+// Reading more than maxBytes (0 means no limit) of decompressed data fails with *http.MaxBytesError,
+// which Decode and RespondError turn into 413. Other encodings are refused with a 415 problem.
+func Decompress(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encodings := contentEncodings(r.Header)
+			if len(encodings) == 0 || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+
+			var (
+				body io.ReadCloser
+				err  error
+			)
+			if len(encodings) > 1 {
+				err = fmt.Errorf("%w: multiple encodings %q", errUnsupportedEncoding, encodings)
+			} else {
+				body, err = newDecoder(encodings[0], r.Body, maxBytes)
+			}
+
+			switch {
+			case errors.Is(err, errUnsupportedEncoding):
+				w.Header().Set("Accept-Encoding", EncodingGzip+", "+EncodingBrotli+", "+EncodingZstd)
+				RespondError(ctx, w, &APIError{Status: http.StatusUnsupportedMediaType, Detail: err.Error(), Err: err})
+				return
+			case err != nil:
+				zlog.GetFromContext(ctx).DebugContext(ctx, "malformed compressed request body", slog.String("encoding", encodings[0]), zlog.Error(err))
+				RespondError(ctx, w, &APIError{Status: http.StatusBadRequest, Detail: "malformed " + encodings[0] + " request body", Err: err})
+				return
+			}
+
+			if maxBytes > 0 {
+				body = http.MaxBytesReader(w, body, maxBytes)
+			}
+
+			r.Body = body
+			r.ContentLength = -1
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package zhttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCompressionConfig() CompressionConfig {
+	return CompressionConfig{
+		Enabled:              true,
+		Encodings:            []string{EncodingZstd, EncodingBrotli, EncodingGzip},
+		Level:                "default",
+		MinSize:              64,
+		ContentTypes:         []string{"application/json", "text/*"},
+		DecompressRequests:   true,
+		MaxDecompressedBytes: 1024,
+	}
+}
+
+func compressBody(t *testing.T, encoding string, body []byte) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	var w io.WriteCloser
+	switch encoding {
+	case EncodingGzip:
+		w = gzip.NewWriter(buf)
+	case EncodingBrotli:
+		w = brotli.NewWriter(buf)
+	case EncodingZstd:
+		var err error
+		w, err = zstd.NewWriter(buf)
+		require.NoError(t, err)
+	default:
+		t.Fatalf("unknown encoding %s", encoding)
+	}
+	_, err := w.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func decompressBody(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+
+	var r io.Reader
+	switch encoding {
+	case EncodingGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		r = zr
+	case EncodingBrotli:
+		r = brotli.NewReader(bytes.NewReader(body))
+	case EncodingZstd:
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		defer zr.Close()
+		r = zr
+	default:
+		return string(body)
+	}
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	return string(b)
+}
+
+func TestAcceptedEncoding(t *testing.T) {
+	t.Parallel()
+
+	encodings := []string{EncodingZstd, EncodingBrotli, EncodingGzip}
+	tests := map[string]struct {
+		header   []string
+		expected string
+	}{
+		"missing":          {header: nil, expected: ""},
+		"single":           {header: []string{"gzip"}, expected: EncodingGzip},
+		"server order":     {header: []string{"gzip, deflate, br"}, expected: EncodingBrotli},
+		"quality":          {header: []string{"zstd;q=0.5, gzip;q=0.8, br;q=0.1"}, expected: EncodingGzip},
+		"multiple headers": {header: []string{"deflate", "gzip;q=0.5"}, expected: EncodingGzip},
+		"wildcard":         {header: []string{"*"}, expected: EncodingZstd},
+		"wildcard exclude": {header: []string{"*, zstd;q=0"}, expected: EncodingBrotli},
+		"excluded":         {header: []string{"gzip;q=0"}, expected: ""},
+		"identity":         {header: []string{"identity"}, expected: ""},
+		"invalid quality":  {header: []string{"br;q=2, gzip"}, expected: EncodingGzip},
+		"case insensitive": {header: []string{"GZip"}, expected: EncodingGzip},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, acceptedEncoding(tc.header, encodings))
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	t.Parallel()
+
+	large := `{"data":"` + strings.Repeat("goboilerplate ", 100) + `"}`
+
+	tests := map[string]struct {
+		acceptEncoding   string
+		handler          http.HandlerFunc
+		expectedEncoding string
+		expectedVary     bool
+		expectedBody     string
+	}{
+		"zstd": {
+			acceptEncoding: "zstd",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				RespondJSON(r.Context(), w, http.StatusOK, nil)
+				_, _ = io.WriteString(w, large)
+			},
+			expectedEncoding: EncodingZstd,
+			expectedVary:     true,
+			expectedBody:     large,
+		},
+		"brotli": {
+			acceptEncoding: "br",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				RespondJSON(r.Context(), w, http.StatusOK, nil)
+				_, _ = io.WriteString(w, large)
+			},
+			expectedEncoding: EncodingBrotli,
+			expectedVary:     true,
+			expectedBody:     large,
+		},
+		"gzip in small writes": {
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				for _, c := range large {
+					_, _ = io.WriteString(w, string(c))
+				}
+			},
+			expectedEncoding: EncodingGzip,
+			expectedVary:     true,
+			expectedBody:     large,
+		},
+		"sniffed content type": {
+			acceptEncoding:   "gzip",
+			handler:          func(w http.ResponseWriter, _ *http.Request) { _, _ = io.WriteString(w, "<html>"+large+"</html>") },
+			expectedEncoding: EncodingGzip,
+			expectedVary:     true,
+			expectedBody:     "<html>" + large + "</html>",
+		},
+		"not accepted": {
+			acceptEncoding: "",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				RespondJSON(r.Context(), w, http.StatusOK, nil)
+				_, _ = io.WriteString(w, large)
+			},
+			expectedEncoding: "",
+			expectedVary:     true,
+			expectedBody:     large,
+		},
+		"below min size": {
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				RespondJSON(r.Context(), w, http.StatusOK, map[string]string{"a": "b"})
+			},
+			expectedEncoding: "",
+			expectedVary:     true,
+			expectedBody:     "{\"a\":\"b\"}\n",
+		},
+		"content type not allowed": {
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				_, _ = io.WriteString(w, large)
+			},
+			expectedEncoding: "",
+			expectedVary:     false,
+			expectedBody:     large,
+		},
+		"already encoded": {
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Content-Encoding", "br")
+				_, _ = io.WriteString(w, large)
+			},
+			expectedEncoding: "br",
+			expectedVary:     true,
+			expectedBody:     "",
+		},
+		"no content": {
+			acceptEncoding:   "gzip",
+			handler:          func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) },
+			expectedEncoding: "",
+			expectedVary:     false,
+			expectedBody:     "",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			h := Compress(t.Context(), testCompressionConfig())(tc.handler)
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+
+			assert.Equal(t, tc.expectedEncoding, resp.Header().Get("Content-Encoding"))
+			if tc.expectedVary {
+				assert.Equal(t, []string{"Accept-Encoding"}, resp.Header().Values("Vary"))
+			} else {
+				assert.Empty(t, resp.Header().Values("Vary"))
+			}
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody, decompressBody(t, tc.expectedEncoding, resp.Body.Bytes()))
+			}
+		})
+	}
+}
+
+func TestCompressHeaders(t *testing.T) {
+	t.Parallel()
+
+	large := strings.Repeat("goboilerplate ", 100)
+	h := Compress(t.Context(), testCompressionConfig())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Vary", "Accept")
+		RespondJSON(r.Context(), w, http.StatusOK, large, WithETag(r))
+	}))
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, EncodingGzip, resp.Header().Get("Content-Encoding"))
+	assert.Empty(t, resp.Header().Get("Content-Length"))
+	assert.Equal(t, []string{"Accept", "Accept-Encoding"}, resp.Header().Values("Vary"))
+	etag := resp.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `W/"`), etag)
+
+	// the weak ETag of the compressed representation revalidates.
+	req.Header.Set("If-None-Match", etag)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusNotModified, resp.Code)
+	assert.Equal(t, etag, resp.Header().Get("ETag"))
+	assert.Empty(t, resp.Body.Bytes())
+}
+
+func TestCompressFlush(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(Compress(t.Context(), testCompressionConfig())(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, "first\n")
+		require.NoError(t, http.NewResponseController(w).Flush())
+		_, _ = io.WriteString(w, "second\n")
+	})))
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "zstd")
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	// a flushed response is compressed despite its size.
+	assert.Equal(t, EncodingZstd, resp.Header.Get("Content-Encoding"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", decompressBody(t, EncodingZstd, body))
+}
+
+func TestDecompress(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte(`{"name":"yoda","age":900}`)
+	bomb := bytes.Repeat([]byte(" "), 4096)
+
+	tests := map[string]struct {
+		contentEncoding string
+		body            []byte
+		expectedStatus  int
+		expectedBody    string
+	}{
+		"identity":          {contentEncoding: "", body: payload, expectedStatus: http.StatusOK, expectedBody: string(payload)},
+		"gzip":              {contentEncoding: "gzip", body: compressBody(t, EncodingGzip, payload), expectedStatus: http.StatusOK, expectedBody: string(payload)},
+		"brotli":            {contentEncoding: "br", body: compressBody(t, EncodingBrotli, payload), expectedStatus: http.StatusOK, expectedBody: string(payload)},
+		"zstd":              {contentEncoding: "zstd", body: compressBody(t, EncodingZstd, payload), expectedStatus: http.StatusOK, expectedBody: string(payload)},
+		"decompressed size": {contentEncoding: "gzip", body: compressBody(t, EncodingGzip, bomb), expectedStatus: http.StatusRequestEntityTooLarge},
+		"unsupported":       {contentEncoding: "deflate", body: payload, expectedStatus: http.StatusUnsupportedMediaType},
+		"multiple":          {contentEncoding: "gzip, br", body: payload, expectedStatus: http.StatusUnsupportedMediaType},
+		"malformed":         {contentEncoding: "gzip", body: payload, expectedStatus: http.StatusBadRequest},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			h := Decompress(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Empty(t, r.Header.Get("Content-Encoding"))
+				b, err := io.ReadAll(r.Body)
+				if err != nil {
+					RespondError(r.Context(), w, err)
+					return
+				}
+				_, _ = w.Write(b)
+			}))
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/", bytes.NewReader(tc.body))
+			if tc.contentEncoding != "" {
+				req.Header.Set("Content-Encoding", tc.contentEncoding)
+			}
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+
+			assert.Equal(t, tc.expectedStatus, resp.Code)
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(t, tc.expectedBody, resp.Body.String())
+			} else {
+				assert.Equal(t, ProblemContentType, resp.Header().Get("Content-Type"))
+			}
+			if tc.expectedStatus == http.StatusUnsupportedMediaType {
+				assert.Equal(t, "gzip, br, zstd", resp.Header().Get("Accept-Encoding"))
+			}
+		})
+	}
+}
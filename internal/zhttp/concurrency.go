@@ -0,0 +1,203 @@
+package zhttp
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Adaptive concurrency limiting algorithms.
+const (
+	ConcurrencyLimitAIMD     = "aimd"
+	ConcurrencyLimitGradient = "gradient"
+)
+
+type ConcurrencyLimitConfig struct {
+	Enabled          bool          `koanf:"enabled"            default:"false"                                           desc:"Limits the requests in flight (public and admin servers) with a limit adapted to the observed latency; requests over it get 503."`
+	Algorithm        string        `koanf:"algorithm"          default:"gradient"     validate:"oneof=aimd gradient"     desc:"aimd decreases the limit multiplicatively when the latency exceeds latency_threshold, and increases it by one otherwise; gradient follows the ratio of the long term to the current latency."`
+	InitialLimit     int           `koanf:"initial_limit"      default:"100"          validate:"min=1"                   desc:"The limit at start."`
+	MinLimit         int           `koanf:"min_limit"          default:"10"           validate:"min=1"                   desc:"The lowest limit."`
+	MaxLimit         int           `koanf:"max_limit"          default:"1000"         validate:"min=1,gtefield=MinLimit" desc:"The highest limit, at least min_limit."`
+	LatencyThreshold time.Duration `koanf:"latency_threshold"  default:"500ms"        validate:"gt=0"                    desc:"aimd: the latency above which the limit decreases."`
+	BackoffRatio     float64       `koanf:"backoff_ratio"      default:"0.9"          validate:"gt=0,lt=1"               desc:"aimd: the factor of the limit decreases."`
+	Tolerance        float64       `koanf:"tolerance"          default:"1.5"          validate:"min=1"                   desc:"gradient: how many times the long term latency the latency may reach before the limit decreases."`
+	LowPriorityRatio float64       `koanf:"low_priority_ratio" default:"0.8"          validate:"gt=0,lte=1"              desc:"Low priority requests are shed once the requests in flight reach this ratio of the limit."`
+	CriticalPaths    []string      `koanf:"critical_paths"     default:"/ping,/about"                                    desc:"The paths, of the public and admin servers alike, that are never shed. A trailing * matches every path with the prefix."`
+	LowPriorityPaths []string      `koanf:"low_priority_paths" default:"/debug/*"                                        desc:"The paths, of the public and admin servers alike, that are shed first. A trailing * matches every path with the prefix."`
+	RetryAfter       time.Duration `koanf:"retry_after"        default:"1s"           validate:"gt=0"                    desc:"The Retry-After of the 503 responses."`
+}
+
+// Priority is the priority class of a request.
+type Priority int
+
+const (
+	PriorityLow      Priority = iota // shed once the requests in flight reach LowPriorityRatio of the limit.
+	PriorityNormal                   // shed once the requests in flight reach the limit.
+	PriorityCritical                 // never shed, e.g. health checks.
+)
+
+// the gradient algorithm.
+const (
+	gradientSmoothing  = 0.2 // the weight of a new limit over the current one.
+	gradientLongWindow = 100 // the samples of the long term latency average.
+)
+
+// ConcurrencyLimiter sheds the requests over an adaptive limit of requests in flight. The limit follows the latency
+// of the requests: as it grows past the capacity of the service, the limit decreases, so that excess requests are
+// rejected early instead of queueing up until they time out.
+type ConcurrencyLimiter struct {
+	c ConcurrencyLimitConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	longRTT  float64 // the exponential moving average of the latency, in seconds (gradient).
+	lastRTT  time.Duration
+	accepted [PriorityCritical + 1]uint64
+	shed     [PriorityCritical + 1]uint64
+}
+
+func NewConcurrencyLimiter(c ConcurrencyLimitConfig) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		c:     c,
+		limit: float64(min(max(c.InitialLimit, c.MinLimit), c.MaxLimit)),
+	}
+}
+
+// Priority returns the priority class of r, by its path.
+func (l *ConcurrencyLimiter) Priority(r *http.Request) Priority {
+	switch {
+	case matchPaths(l.c.CriticalPaths, r.URL.Path):
+		return PriorityCritical
+	case matchPaths(l.c.LowPriorityPaths, r.URL.Path):
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
+
+func matchPaths(patterns []string, path string) bool {
+	for _, p := range patterns {
+		if prefix, isPrefix := strings.CutSuffix(p, "*"); (isPrefix && strings.HasPrefix(path, prefix)) || p == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Middleware admits the requests under the limit of their priority class and responds to the rest with a 503
+// problem and Retry-After. The latency of every admitted, normal priority, request adapts the limit.
+func (l *ConcurrencyLimiter) Middleware(next http.Handler) http.Handler {
+	retryAfter := ceilSeconds(l.c.RetryAfter)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := l.Priority(r)
+		if !l.acquire(p) {
+			w.Header().Set("Retry-After", retryAfter)
+			RespondError(r.Context(), w, NewAPIError(http.StatusServiceUnavailable, "The service is overloaded."))
+			return
+		}
+
+		start := time.Now()
+		defer func() { l.release(p, time.Since(start)) }()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *ConcurrencyLimiter) acquire(p Priority) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit := l.limit
+	if p == PriorityLow {
+		limit *= l.c.LowPriorityRatio
+	}
+
+	if p != PriorityCritical && float64(l.inflight) >= math.Floor(limit) {
+		l.shed[p]++
+		return false
+	}
+
+	l.inflight++
+	l.accepted[p]++
+
+	return true
+}
+
+func (l *ConcurrencyLimiter) release(p Priority, rtt time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	inflight := l.inflight
+	l.inflight--
+
+	// critical requests (e.g. health checks) are cheap and low priority ones (e.g. profiles) slow by design,
+	// they would skew the latency.
+	if p != PriorityNormal {
+		return
+	}
+
+	l.lastRTT = rtt
+	// the limit grows only when it is being used; an idle service tells nothing about its capacity.
+	used := float64(inflight)*2 >= l.limit
+
+	switch l.c.Algorithm {
+	case ConcurrencyLimitAIMD:
+		switch {
+		case rtt > l.c.LatencyThreshold:
+			l.limit *= l.c.BackoffRatio
+		case used:
+			l.limit++
+		}
+
+	case ConcurrencyLimitGradient:
+		sample := rtt.Seconds()
+		if l.longRTT == 0 {
+			l.longRTT = sample
+		} else {
+			l.longRTT += (sample - l.longRTT) * 2 / (gradientLongWindow + 1)
+		}
+
+		// the ratio of the long term to the current latency: below 1 the requests queue up.
+		gradient := max(0.5, min(1, l.c.Tolerance*l.longRTT/max(sample, 1e-9)))
+		// the square root of the limit leaves room for requests to queue up, so the limit can grow.
+		next := l.limit*gradient + math.Sqrt(l.limit)
+		if next > l.limit && !used {
+			return
+		}
+		l.limit = l.limit*(1-gradientSmoothing) + next*gradientSmoothing
+	}
+
+	l.limit = min(max(l.limit, float64(l.c.MinLimit)), float64(l.c.MaxLimit))
+}
+
+// ConcurrencyLimiterStats are the metrics of a ConcurrencyLimiter, by priority class (low, normal and critical).
+type ConcurrencyLimiterStats struct {
+	Limit    int               `json:"limit"`
+	InFlight int               `json:"in_flight"`
+	Latency  time.Duration     `json:"latency_ns"` // of the last normal priority request.
+	Accepted map[string]uint64 `json:"accepted"`
+	Shed     map[string]uint64 `json:"shed"`
+}
+
+// Stats returns the current metrics of l, e.g. to publish them with expvar.Func.
+func (l *ConcurrencyLimiter) Stats() ConcurrencyLimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	byClass := func(counts [PriorityCritical + 1]uint64) map[string]uint64 {
+		return map[string]uint64{"low": counts[PriorityLow], "normal": counts[PriorityNormal], "critical": counts[PriorityCritical]}
+	}
+
+	return ConcurrencyLimiterStats{
+		Limit:    int(l.limit),
+		InFlight: l.inflight,
+		Latency:  l.lastRTT,
+		Accepted: byClass(l.accepted),
+		Shed:     byClass(l.shed),
+	}
+}
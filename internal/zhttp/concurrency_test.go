@@ -0,0 +1,219 @@
+package zhttp
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestConcurrencyLimiter(algorithm string) *ConcurrencyLimiter {
+	return NewConcurrencyLimiter(ConcurrencyLimitConfig{
+		Enabled:          true,
+		Algorithm:        algorithm,
+		InitialLimit:     100,
+		MinLimit:         10,
+		MaxLimit:         200,
+		LatencyThreshold: 100 * time.Millisecond,
+		BackoffRatio:     0.5,
+		Tolerance:        1.5,
+		LowPriorityRatio: 0.5,
+		CriticalPaths:    []string{"/ping", "/about"},
+		LowPriorityPaths: []string{"/debug/*"},
+		RetryAfter:       1500 * time.Millisecond,
+	})
+}
+
+func TestConcurrencyLimiterPriority(t *testing.T) {
+	t.Parallel()
+
+	l := newTestConcurrencyLimiter(ConcurrencyLimitGradient)
+
+	tests := map[string]Priority{
+		"/ping":            PriorityCritical,
+		"/about":           PriorityCritical,
+		"/about/more":      PriorityNormal,
+		"/debug/pprof/":    PriorityLow,
+		"/debug":           PriorityNormal,
+		"/echo":            PriorityNormal,
+		"/api/ping":        PriorityNormal,
+		"/debug/vars?x=ok": PriorityLow,
+	}
+
+	for target, expected := range tests {
+		t.Run(target, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, target, nil)
+			assert.Equal(t, expected, l.Priority(req))
+		})
+	}
+}
+
+func TestConcurrencyLimiterMiddleware(t *testing.T) {
+	t.Parallel()
+
+	l := newTestConcurrencyLimiter(ConcurrencyLimitAIMD)
+	l.limit, l.c.MaxLimit = 10, 10
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }))
+
+	do := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, target, nil)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// requests in flight, held by slow handlers.
+	for range 5 {
+		require.True(t, l.acquire(PriorityNormal))
+	}
+
+	// low priority requests are shed at half the limit.
+	resp := do("/debug/vars")
+	require.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	assert.Equal(t, ProblemContentType, resp.Header().Get("Content-Type"))
+	assert.Equal(t, "2", resp.Header().Get("Retry-After"))
+	problem := APIError{}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &problem))
+	assert.Equal(t, "The service is overloaded.", problem.Detail)
+
+	assert.Equal(t, http.StatusNoContent, do("/echo").Code)
+
+	for range 5 {
+		require.True(t, l.acquire(PriorityNormal))
+	}
+
+	// normal requests are shed at the limit, critical ones never.
+	assert.Equal(t, http.StatusServiceUnavailable, do("/echo").Code)
+	assert.Equal(t, http.StatusNoContent, do("/ping").Code)
+	assert.Equal(t, http.StatusNoContent, do("/about").Code)
+
+	stats := l.Stats()
+	assert.Equal(t, 10, stats.InFlight)
+	assert.Equal(t, map[string]uint64{"low": 0, "normal": 11, "critical": 2}, stats.Accepted)
+	assert.Equal(t, map[string]uint64{"low": 1, "normal": 1, "critical": 0}, stats.Shed)
+}
+
+func TestConcurrencyLimiterAIMD(t *testing.T) {
+	t.Parallel()
+
+	l := newTestConcurrencyLimiter(ConcurrencyLimitAIMD)
+
+	// the limit grows by one per request only when at least half of it is in use.
+	l.acquire(PriorityNormal)
+	l.release(PriorityNormal, 10*time.Millisecond)
+	assert.Equal(t, 100, l.Stats().Limit)
+
+	l.inflight = 60
+	l.acquire(PriorityNormal)
+	l.release(PriorityNormal, 10*time.Millisecond)
+	assert.Equal(t, 101, l.Stats().Limit)
+	assert.Equal(t, 10*time.Millisecond, l.Stats().Latency)
+
+	// it decreases multiplicatively when the latency exceeds the threshold, even when idle.
+	l.inflight = 0
+	l.acquire(PriorityNormal)
+	l.release(PriorityNormal, 200*time.Millisecond)
+	assert.Equal(t, 50, l.Stats().Limit)
+
+	// critical requests do not count, nor do low priority ones (e.g. a slow profile of the admin server).
+	l.acquire(PriorityCritical)
+	l.release(PriorityCritical, time.Second)
+	l.acquire(PriorityLow)
+	l.release(PriorityLow, time.Second)
+	assert.Equal(t, 50, l.Stats().Limit)
+	assert.Equal(t, 200*time.Millisecond, l.Stats().Latency)
+
+	// within bounds.
+	for range 10 {
+		l.acquire(PriorityNormal)
+		l.release(PriorityNormal, time.Second)
+	}
+	assert.Equal(t, 10, l.Stats().Limit)
+
+	l.inflight = 150
+	for range 300 {
+		l.acquire(PriorityCritical)
+		l.release(PriorityNormal, time.Millisecond)
+	}
+	assert.Equal(t, 200, l.Stats().Limit)
+}
+
+func TestConcurrencyLimiterGradient(t *testing.T) {
+	t.Parallel()
+
+	l := newTestConcurrencyLimiter(ConcurrencyLimitGradient)
+	sample := func(rtt time.Duration) int {
+		l.acquire(PriorityNormal)
+		l.release(PriorityNormal, rtt)
+		return l.Stats().Limit
+	}
+
+	// a steady latency does not grow an idle limit.
+	for range 50 {
+		sample(10 * time.Millisecond)
+	}
+	assert.Equal(t, 100, l.Stats().Limit)
+
+	// it grows while in use.
+	l.inflight = 150
+	assert.Greater(t, sample(10*time.Millisecond), 100)
+	for range 100 {
+		sample(10 * time.Millisecond)
+	}
+	assert.Equal(t, 200, l.Stats().Limit)
+
+	// latency within the tolerance leaves room to grow.
+	l.inflight = 0
+	assert.Equal(t, 200, sample(14*time.Millisecond))
+
+	// a latency spike decreases it, until the long term latency catches up.
+	assert.Less(t, sample(100*time.Millisecond), 200)
+	for range 20 {
+		sample(100 * time.Millisecond)
+	}
+	assert.Less(t, l.Stats().Limit, 50)
+	for range 100 {
+		sample(100 * time.Millisecond)
+	}
+	settled := l.Stats().Limit
+	assert.Equal(t, settled, sample(100*time.Millisecond))
+
+	// down to the min.
+	l.c.MinLimit = 40
+	assert.Equal(t, 40, sample(time.Second))
+}
+
+func TestDefaultRouterConcurrencyLimit(t *testing.T) {
+	t.Parallel()
+
+	l := newTestConcurrencyLimiter(ConcurrencyLimitAIMD)
+	l.limit, l.c.MaxLimit = 10, 10
+	for range 10 {
+		require.True(t, l.acquire(PriorityNormal))
+	}
+
+	c := Config{
+		CORS:            CORSConfig{Enabled: true, AllowedOrigins: []string{"https://app.example.com"}, AllowedMethods: []string{http.MethodGet}},
+		SecurityHeaders: SecurityHeadersConfig{Enabled: true, ContentTypeNosniff: true, FrameOptions: "deny"},
+	}
+	router := NewDefaultRouter(t.Context(), c, Authenticators{}, l, slog.Default())
+
+	// the shed requests are readable by the page, and carry the security headers and the request id.
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/echo", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	assert.Equal(t, "https://app.example.com", resp.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "nosniff", resp.Header().Get("X-Content-Type-Options"))
+	problem := APIError{}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &problem))
+	assert.NotEmpty(t, problem.RequestID)
+}
@@ -0,0 +1,143 @@
+package zhttp
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CORSConfig struct {
+	Enabled          bool          `koanf:"enabled"           default:"false"                                                                                                          desc:"Handles cross-origin requests (CORS) of browsers, preflights included."`
+	AllowedOrigins   []string      `koanf:"allowed_origins"                                                                                                                            desc:"The origins allowed to call the API, e.g. https://app.example.com. A * matches any subdomain (e.g. https://*.example.com), and a single * any origin, though never with credentials."`
+	AllowedMethods   []string      `koanf:"allowed_methods"   default:"GET,HEAD,POST,PUT,PATCH,DELETE"                                                                                 desc:"The methods allowed to cross-origin requests."`
+	AllowedHeaders   []string      `koanf:"allowed_headers"   default:"Accept,Authorization,Content-Type,X-API-Key,X-Request-Id"                                                       desc:"The request headers allowed to cross-origin requests. * allows any."`
+	ExposedHeaders   []string      `koanf:"exposed_headers"   default:"Retry-After,RateLimit-Policy,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,X-Request-Id"                  desc:"The response headers, besides the CORS safelisted ones, readable by cross-origin requests."`
+	AllowCredentials bool          `koanf:"allow_credentials" default:"false"                                                                                                          desc:"Allows cross-origin requests with credentials (cookies, Authorization)."`
+	MaxAge           time.Duration `koanf:"max_age"           default:"10m"                                                                                           validate:"min=0" desc:"How long browsers may cache a preflight response. 0 leaves it to the browser."`
+}
+
+// CORS returns a middleware that handles the cross-origin requests of c.AllowedOrigins: it answers their preflights
+// with 204, without calling next, and adds the CORS headers to their responses. Requests of other origins are served
+// without CORS headers, so browsers do not hand the responses to the calling page.
+func CORS(c CORSConfig) func(http.Handler) http.Handler {
+	anyOrigin := slices.Contains(c.AllowedOrigins, "*")
+	anyHeader := slices.Contains(c.AllowedHeaders, "*")
+	methods := strings.Join(c.AllowedMethods, ", ")
+	exposed := strings.Join(c.ExposedHeaders, ", ")
+	maxAge := ""
+	if c.MaxAge > 0 {
+		maxAge = strconv.Itoa(int(c.MaxAge.Seconds()))
+	}
+
+	// allowOrigin returns the Access-Control-Allow-Origin of origin and whether credentials are allowed along.
+	allowOrigin := func(origin string) (string, bool) {
+		for _, o := range c.AllowedOrigins {
+			if o != "*" && matchOrigin(o, origin) {
+				return origin, c.AllowCredentials
+			}
+		}
+		if anyOrigin {
+			// the fetch standard rejects credentialed responses for *; reflecting any origin along with credentials
+			// would let every site act on behalf of the user.
+			return "*", false
+		}
+
+		return "", false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			addVary(h, "Origin")
+			if preflight {
+				addVary(h, "Access-Control-Request-Method")
+				addVary(h, "Access-Control-Request-Headers")
+			}
+
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, credentials := allowOrigin(origin)
+
+			if !preflight {
+				if allowed != "" {
+					h.Set("Access-Control-Allow-Origin", allowed)
+					if credentials {
+						h.Set("Access-Control-Allow-Credentials", "true")
+					}
+					if exposed != "" {
+						h.Set("Access-Control-Expose-Headers", exposed)
+					}
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			requested := r.Header.Get("Access-Control-Request-Headers")
+			if allowed == "" ||
+				!slices.Contains(c.AllowedMethods, r.Header.Get("Access-Control-Request-Method")) ||
+				(!anyHeader && !allowedHeaders(c.AllowedHeaders, requested)) {
+				// without the CORS headers the browser fails the actual request.
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			h.Set("Access-Control-Allow-Origin", allowed)
+			if credentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			h.Set("Access-Control-Allow-Methods", methods)
+			if requested != "" {
+				// the requested headers, as a * would not cover Authorization.
+				h.Set("Access-Control-Allow-Headers", requested)
+			}
+			if maxAge != "" {
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// matchOrigin reports whether origin matches pattern, which may have a single * standing for one or more subdomain
+// labels (e.g. https://*.example.com).
+func matchOrigin(pattern, origin string) bool {
+	prefix, suffix, wildcard := strings.Cut(pattern, "*")
+	if !wildcard {
+		return strings.EqualFold(pattern, origin)
+	}
+
+	if !strings.HasPrefix(suffix, ".") ||
+		len(origin) <= len(prefix)+len(suffix) ||
+		!strings.EqualFold(origin[:len(prefix)], prefix) ||
+		!strings.EqualFold(origin[len(origin)-len(suffix):], suffix) {
+		return false
+	}
+
+	// the wildcard covers host labels only, never a scheme, a port or a path.
+	labels := origin[len(prefix) : len(origin)-len(suffix)]
+
+	return !strings.ContainsAny(labels, "/:@?#") && !strings.HasPrefix(labels, ".") && !strings.HasSuffix(labels, ".")
+}
+
+// allowedHeaders reports whether every header of the comma separated list requested is allowed (case insensitively).
+func allowedHeaders(allowed []string, requested string) bool {
+	for name := range strings.SplitSeq(requested, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.ContainsFunc(allowed, func(a string) bool { return strings.EqualFold(a, name) }) {
+			return false
+		}
+	}
+
+	return true
+}
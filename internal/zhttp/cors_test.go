@@ -0,0 +1,245 @@
+package zhttp
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern  string
+		origin   string
+		expected bool
+	}{
+		{pattern: "https://app.example.com", origin: "https://app.example.com", expected: true},
+		{pattern: "https://app.example.com", origin: "https://APP.example.com", expected: true},
+		{pattern: "https://app.example.com", origin: "http://app.example.com", expected: false},
+		{pattern: "https://app.example.com", origin: "https://app.example.com:8443", expected: false},
+		{pattern: "https://*.example.com", origin: "https://app.example.com", expected: true},
+		{pattern: "https://*.example.com", origin: "https://a.b.example.com", expected: true},
+		{pattern: "https://*.example.com", origin: "https://example.com", expected: false},
+		{pattern: "https://*.example.com", origin: "https://.example.com", expected: false},
+		{pattern: "https://*.example.com", origin: "https://evil.com/.example.com", expected: false},
+		{pattern: "https://*.example.com", origin: "https://evil.com:1@x.example.com", expected: false},
+		{pattern: "https://*.example.com", origin: "https://app.example.com.evil.com", expected: false},
+		{pattern: "https://*.example.com", origin: "http://app.example.com", expected: false},
+		{pattern: "http://localhost:*", origin: "http://localhost:3000", expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.pattern+" "+tc.origin, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, matchOrigin(tc.pattern, tc.origin))
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	t.Parallel()
+
+	c := CORSConfig{
+		Enabled:        true,
+		AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		ExposedHeaders: []string{"X-Request-Id"},
+		MaxAge:         10 * time.Minute,
+	}
+
+	tests := map[string]struct {
+		config   func(c CORSConfig) CORSConfig
+		method   string
+		headers  map[string]string
+		status   int
+		expected map[string]string // the expected response headers; empty values are expected to be absent.
+	}{
+		"same origin": {
+			method: http.MethodGet,
+			status: http.StatusTeapot,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin": "",
+				"Vary":                        "Origin",
+			},
+		},
+		"allowed origin": {
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://app.example.com"},
+			status:  http.StatusTeapot,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Expose-Headers":    "X-Request-Id",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		"not allowed origin": {
+			method:  http.MethodPost,
+			headers: map[string]string{"Origin": "https://evil.com"},
+			status:  http.StatusTeapot,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":   "",
+				"Access-Control-Expose-Headers": "",
+			},
+		},
+		"credentials": {
+			config:  func(c CORSConfig) CORSConfig { c.AllowCredentials = true; return c },
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://api.example.org"},
+			status:  http.StatusTeapot,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "https://api.example.org",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		"any origin is never credentialed": {
+			config: func(c CORSConfig) CORSConfig {
+				c.AllowedOrigins = append(c.AllowedOrigins, "*")
+				c.AllowCredentials = true
+				return c
+			},
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://evil.com"},
+			status:  http.StatusTeapot,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		"preflight": {
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  http.MethodPost,
+				"Access-Control-Request-Headers": "authorization,content-type",
+			},
+			status: http.StatusNoContent,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "authorization,content-type",
+				"Access-Control-Max-Age":       "600",
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+		"preflight of a not allowed method": {
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "https://app.example.com",
+				"Access-Control-Request-Method": http.MethodDelete,
+			},
+			status: http.StatusNoContent,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Methods": "",
+			},
+		},
+		"preflight of a not allowed header": {
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  http.MethodGet,
+				"Access-Control-Request-Headers": "authorization,x-custom",
+			},
+			status: http.StatusNoContent,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Headers": "",
+			},
+		},
+		"preflight of any header": {
+			config: func(c CORSConfig) CORSConfig { c.AllowedHeaders = []string{"*"}; return c },
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  http.MethodGet,
+				"Access-Control-Request-Headers": "x-custom",
+			},
+			status: http.StatusNoContent,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Headers": "x-custom",
+			},
+		},
+		"options without a preflight": {
+			method:  http.MethodOptions,
+			headers: map[string]string{"Origin": "https://app.example.com"},
+			status:  http.StatusTeapot,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Methods": "",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := c
+			if tc.config != nil {
+				config = tc.config(c)
+			}
+			handler := CORS(config)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusTeapot) }))
+
+			req := httptest.NewRequestWithContext(t.Context(), tc.method, "/items", nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+
+			assert.Equal(t, tc.status, resp.Code)
+			for k, v := range tc.expected {
+				if k == "Vary" {
+					assert.Equal(t, v, strings.Join(resp.Header().Values(k), ", "), k)
+					continue
+				}
+				assert.Equal(t, v, resp.Header().Get(k), k)
+			}
+		})
+	}
+}
+
+func TestDefaultRouterCORS(t *testing.T) {
+	t.Parallel()
+
+	c := Config{
+		CORS: CORSConfig{
+			Enabled:        true,
+			AllowedOrigins: []string{"https://app.example.com"},
+			AllowedMethods: []string{http.MethodGet},
+			AllowedHeaders: []string{"Authorization"},
+		},
+		SecurityHeaders: SecurityHeadersConfig{Enabled: true, ContentTypeNosniff: true, FrameOptions: "deny"},
+	}
+	router := NewDefaultRouter(t.Context(), c, Authenticators{}, nil, slog.Default())
+
+	// preflights carry no credentials, they are answered ahead of the authentication.
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodOptions, "/echo", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "authorization")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	assert.Equal(t, "https://app.example.com", resp.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "authorization", resp.Header().Get("Access-Control-Allow-Headers"))
+
+	// errors are readable by the page, and carry the security headers.
+	req = httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/echo", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.Equal(t, "https://app.example.com", resp.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "nosniff", resp.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", resp.Header().Get("X-Frame-Options"))
+}
@@ -0,0 +1,95 @@
+'use strict';
+
+// Renders the OpenAPI document served by zhttp.OpenAPIHandler.
+(async function () {
+  const main = document.getElementById('operations');
+
+  let doc;
+  try {
+    const resp = await fetch('../openapi.json', { headers: { Accept: 'application/json' } });
+    if (!resp.ok) throw new Error(resp.status + ' ' + resp.statusText);
+    doc = await resp.json();
+  } catch (err) {
+    main.textContent = 'Could not load openapi.json: ' + err.message;
+    return;
+  }
+
+  document.title = doc.info.title + ' ' + doc.info.version;
+  document.getElementById('title').textContent = doc.info.title + ' ' + doc.info.version;
+  document.getElementById('description').textContent = doc.info.description || '';
+
+  const schemas = (doc.components && doc.components.schemas) || {};
+
+  // resolve inlines the referenced components (once per branch, to stop at recursive types).
+  function resolve(schema, seen) {
+    if (Array.isArray(schema)) return schema.map((s) => resolve(s, seen));
+    if (!schema || typeof schema !== 'object') return schema;
+    if (schema.$ref) {
+      const name = schema.$ref.replace('#/components/schemas/', '');
+      if (seen.has(name)) return { $ref: schema.$ref };
+      return resolve(schemas[name], new Set([...seen, name]));
+    }
+    const out = {};
+    for (const [k, v] of Object.entries(schema)) out[k] = resolve(v, seen);
+    return out;
+  }
+
+  function el(tag, attrs, ...children) {
+    const e = document.createElement(tag);
+    Object.assign(e, attrs);
+    for (const c of children) e.append(c);
+    return e;
+  }
+
+  function schemaBlock(schema) {
+    return el('pre', {}, JSON.stringify(resolve(schema, new Set()), null, 2));
+  }
+
+  function contentBlocks(content) {
+    const out = [];
+    for (const [mediaType, mt] of Object.entries(content || {})) {
+      out.push(el('p', {}, el('code', {}, mediaType)));
+      out.push(schemaBlock(mt.schema));
+      break; // every media type has the same schema.
+    }
+    const others = Object.keys(content || {}).slice(1);
+    if (others.length) out.push(el('p', {}, 'Also: ' + others.join(', ')));
+    return out;
+  }
+
+  main.textContent = '';
+  for (const [path, methods] of Object.entries(doc.paths).sort(([a], [b]) => a.localeCompare(b))) {
+    for (const [method, op] of Object.entries(methods)) {
+      const security = (op.security || []).map((s) => Object.keys(s).join(' + ')).join(' or ');
+      const summary = el('summary', {},
+        el('span', { className: 'method ' + method }, method),
+        el('span', { className: 'path' }, path),
+        el('span', {}, op.summary || ''),
+        el('span', { className: 'lock' }, security ? 'auth: ' + security : ''));
+
+      const body = el('div', { className: 'body' });
+      if (op.description) body.append(el('p', {}, op.description));
+
+      if (op.parameters && op.parameters.length) {
+        const rows = op.parameters.map((p) => el('tr', {},
+          el('td', {}, el('code', {}, p.name)),
+          el('td', {}, p.in),
+          el('td', {}, p.required ? 'yes' : 'no'),
+          el('td', {}, JSON.stringify(p.schema)),
+          el('td', {}, p.description || '')));
+        body.append(el('h4', {}, 'Parameters'), el('table', {},
+          el('tr', {}, ...['Name', 'In', 'Required', 'Schema', 'Description'].map((h) => el('th', {}, h))), ...rows));
+      }
+
+      if (op.requestBody) {
+        body.append(el('h4', {}, 'Request body' + (op.requestBody.required ? '' : ' (optional)')), ...contentBlocks(op.requestBody.content));
+      }
+
+      for (const [status, r] of Object.entries(op.responses || {})) {
+        body.append(el('h4', {}, 'Response ' + status + ': ' + r.description), ...contentBlocks(r.content));
+      }
+
+      main.append(el('details', { className: op.deprecated ? 'deprecated' : '' }, summary, body));
+    }
+  }
+})();
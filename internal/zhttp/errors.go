@@ -0,0 +1,172 @@
+package zhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/moukoublen/goboilerplate/internal/validate"
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+)
+
+// ProblemContentType is the media type of the error responses (RFC 9457).
+const ProblemContentType = "application/problem+json"
+
+// StatusClientClosedRequest is the (non standard) status of requests whose client went away before the response.
+const StatusClientClosedRequest = 499
+
+// APIError is an error response, rendered as RFC 9457 problem details by RespondError.
+type APIError struct {
+	Status    int          `json:"status"`
+	Type      string       `json:"type"`             // a URI reference of the problem type; about:blank when empty.
+	Title     string       `json:"title"`            // a short summary of the problem type; the status text when empty.
+	Detail    string       `json:"detail,omitempty"` // explanation specific to this occurrence of the problem.
+	Instance  string       `json:"instance,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+
+	// Err is the cause. It is logged but never rendered.
+	Err error `json:"-"`
+}
+
+// FieldError is a single invalid field of the request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+// NewAPIError returns an *APIError of status with the given detail.
+func NewAPIError(status int, detail string) *APIError {
+	return &APIError{Status: status, Detail: detail}
+}
+
+func (e *APIError) Error() string {
+	s := http.StatusText(e.Status)
+	if e.Title != "" {
+		s = e.Title
+	}
+	if e.Detail != "" {
+		s += ": " + e.Detail
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+
+	return s
+}
+
+func (e *APIError) Unwrap() error { return e.Err }
+
+// FieldErrors converts validation errors to the field errors of an APIError.
+func FieldErrors(errs validate.Errors) []FieldError {
+	out := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		out = append(out, FieldError{Field: fe.Path, Rule: fe.Rule, Message: fe.Message})
+	}
+
+	return out
+}
+
+// ErrorMapper maps an error (e.g. a domain error) to an *APIError. It returns nil for errors it does not handle.
+type ErrorMapper func(error) *APIError
+
+//nolint:gochecknoglobals
+var (
+	errorMappersMu sync.RWMutex
+	errorMappers   []ErrorMapper
+)
+
+// RegisterErrorMapper registers m in the central error mapping of ToAPIError. Mappers registered later take precedence.
+func RegisterErrorMapper(m ErrorMapper) {
+	errorMappersMu.Lock()
+	defer errorMappersMu.Unlock()
+
+	errorMappers = append(errorMappers, m)
+}
+
+// ToAPIError maps err to an *APIError: an *APIError in the chain is used as is, then the registered mappers are tried,
+// then the well known errors (validation, size limit, deadline, cancellation). Anything else becomes a 500 without detail.
+func ToAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	errorMappersMu.RLock()
+	for i := len(errorMappers) - 1; i >= 0; i-- {
+		if apiErr = errorMappers[i](err); apiErr != nil {
+			errorMappersMu.RUnlock()
+			mapped := *apiErr // a copy, as mappers may return a shared value.
+			if mapped.Err == nil {
+				mapped.Err = err
+			}
+			return &mapped
+		}
+	}
+	errorMappersMu.RUnlock()
+
+	var (
+		validationErrs validate.Errors
+		maxBytesErr    *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &validationErrs):
+		return &APIError{Status: http.StatusBadRequest, Detail: "The request is invalid.", Errors: FieldErrors(validationErrs), Err: err}
+	case errors.As(err, &maxBytesErr):
+		return &APIError{Status: http.StatusRequestEntityTooLarge, Err: err}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &APIError{Status: http.StatusGatewayTimeout, Err: err}
+	case errors.Is(err, context.Canceled):
+		return &APIError{Status: StatusClientClosedRequest, Title: "Client Closed Request", Err: err}
+	default:
+		return &APIError{Status: http.StatusInternalServerError, Err: err}
+	}
+}
+
+// RespondError renders err (mapped through ToAPIError) as application/problem+json, along with the request id.
+// Server errors are logged at error level, client errors at debug level.
+func RespondError(ctx context.Context, w http.ResponseWriter, err error) {
+	apiErr := *ToAPIError(err) // a copy, as the mapped error can be shared.
+	if apiErr.Status == 0 {
+		apiErr.Status = http.StatusInternalServerError
+	}
+	if apiErr.Type == "" {
+		apiErr.Type = "about:blank"
+	}
+	if apiErr.Title == "" {
+		apiErr.Title = http.StatusText(apiErr.Status)
+	}
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = middleware.GetReqID(ctx)
+	}
+
+	logger := zlog.GetFromContext(ctx)
+	level := slog.LevelDebug
+	if apiErr.Status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	logger.Log(ctx, level, "error response", slog.Int("status", apiErr.Status), slog.String("request_id", apiErr.RequestID), zlog.Error(err))
+
+	w.Header().Set(`Content-Type`, ProblemContentType)
+	w.Header().Set(`Cache-Control`, `no-store`)
+	w.WriteHeader(apiErr.Status)
+
+	if err := json.NewEncoder(w).Encode(apiErr); err != nil {
+		logger.ErrorContext(ctx, "error during response encoding", zlog.Error(err))
+	}
+}
+
+// NotFoundHandler renders a 404 problem.
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	RespondError(r.Context(), w, &APIError{Status: http.StatusNotFound})
+}
+
+// MethodNotAllowedHandler renders a 405 problem.
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	RespondError(r.Context(), w, &APIError{Status: http.StatusMethodNotAllowed})
+}
@@ -0,0 +1,121 @@
+package zhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/moukoublen/goboilerplate/internal/validate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	errTestNotFound = errors.New("test entity not found")
+	errTestGone     = errors.New("test entity gone")
+)
+
+// registerTestErrorMapper registers m for the duration of the test.
+func registerTestErrorMapper(t *testing.T, m ErrorMapper) {
+	t.Helper()
+
+	errorMappersMu.Lock()
+	registered := slices.Clone(errorMappers)
+	errorMappersMu.Unlock()
+
+	RegisterErrorMapper(m)
+	t.Cleanup(func() {
+		errorMappersMu.Lock()
+		defer errorMappersMu.Unlock()
+		errorMappers = registered
+	})
+}
+
+func TestToAPIError(t *testing.T) {
+	gone := &APIError{Status: http.StatusGone, Detail: "gone"} // shared by every mapped error.
+	registerTestErrorMapper(t, func(err error) *APIError {
+		switch {
+		case errors.Is(err, errTestNotFound):
+			return &APIError{Status: http.StatusNotFound, Type: "https://example.com/problems/not-found", Detail: err.Error()}
+		case errors.Is(err, errTestGone):
+			return gone
+		}
+		return nil
+	})
+
+	apiErr := &APIError{Status: http.StatusConflict, Detail: "already exists"}
+
+	tests := map[string]struct {
+		err            error
+		expectedStatus int
+		expectedDetail string
+		expectedErrors []FieldError
+	}{
+		"api error":        {err: fmt.Errorf("wrapped: %w", apiErr), expectedStatus: http.StatusConflict, expectedDetail: "already exists"},
+		"registered":       {err: fmt.Errorf("user 1: %w", errTestNotFound), expectedStatus: http.StatusNotFound, expectedDetail: "user 1: test entity not found"},
+		"deadline":         {err: fmt.Errorf("query: %w", context.DeadlineExceeded), expectedStatus: http.StatusGatewayTimeout},
+		"canceled":         {err: context.Canceled, expectedStatus: StatusClientClosedRequest},
+		"body too large":   {err: &http.MaxBytesError{Limit: 1}, expectedStatus: http.StatusRequestEntityTooLarge},
+		"validation":       {err: validate.Errors{{Path: "name", Rule: "required", Message: "is required"}}, expectedStatus: http.StatusBadRequest, expectedDetail: "The request is invalid.", expectedErrors: []FieldError{{Field: "name", Rule: "required", Message: "is required"}}},
+		"unknown (hidden)": {err: errors.New("db password is wrong"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := ToAPIError(tc.err)
+			assert.Equal(t, tc.expectedStatus, got.Status)
+			assert.Equal(t, tc.expectedDetail, got.Detail)
+			assert.Equal(t, tc.expectedErrors, got.Errors)
+		})
+	}
+
+	// the mapped errors carry their own cause, the shared value of the mapper is left untouched.
+	err1, err2 := fmt.Errorf("user 1: %w", errTestGone), fmt.Errorf("user 2: %w", errTestGone)
+	got1, got2 := ToAPIError(err1), ToAPIError(err2)
+	assert.Equal(t, err1, got1.Err)
+	assert.Equal(t, err2, got2.Err)
+	assert.Equal(t, http.StatusGone, got2.Status)
+	assert.Nil(t, gone.Err)
+}
+
+func TestRespondError(t *testing.T) {
+	t.Parallel()
+
+	resp := httptest.NewRecorder()
+	ctx := context.WithValue(t.Context(), middleware.RequestIDKey, "req-1")
+	RespondError(ctx, resp, errors.New("secret cause"))
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Equal(t, ProblemContentType, resp.Header().Get("Content-Type"))
+
+	body := map[string]any{}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, map[string]any{
+		"status":     float64(http.StatusInternalServerError),
+		"type":       "about:blank",
+		"title":      "Internal Server Error",
+		"request_id": "req-1",
+	}, body)
+}
+
+func TestProblemRouters(t *testing.T) {
+	t.Parallel()
+
+	router := NewAdminRouter(t.Context(), Config{}, nil)
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/nope", nil))
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, ProblemContentType, resp.Header().Get("Content-Type"))
+
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/about", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+	assert.Equal(t, ProblemContentType, resp.Header().Get("Content-Type"))
+}
@@ -0,0 +1,368 @@
+package zhttp
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/moukoublen/goboilerplate/internal/validate"
+)
+
+// Struct tags of the request fields bound by Bind.
+const (
+	tagPath   = "path"
+	tagQuery  = "query"
+	tagHeader = "header"
+	tagBody   = "body"
+)
+
+// StatusCoder can be implemented by the responses of Handle to respond with a status other than 200.
+// A 204 response is sent without body.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// NoContent is the response of handlers that respond with 204 and no body.
+type NoContent struct{}
+
+func (NoContent) StatusCode() int { return http.StatusNoContent }
+
+// Handle adapts a typed handler to an http.HandlerFunc. The request is bound into Req (see Bind) and validated
+// with the `validate` tags; the response is rendered with the negotiated codec (see Respond), using the status of
+// Resp if it is a StatusCoder. Binding, validation and handler errors are rendered by RespondError. Handle panics if
+// Req has parameters of a type Bind does not support.
+//
+//	type GetUserRequest struct {
+//		ID      string `path:"id"         validate:"required"`
+//		Verbose bool   `query:"verbose"`
+//		TraceID string `header:"X-Trace-Id"`
+//	}
+//
+//	router.Get("/users/{id}", zhttp.Handle(func(ctx context.Context, req GetUserRequest) (User, error) { ... }))
+func Handle[Req, Resp any](fn func(context.Context, Req) (Resp, error)) http.HandlerFunc {
+	if err := checkBindable(reflect.TypeFor[Req]()); err != nil {
+		panic(err)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var req Req
+		if err := Bind(r, &req); err != nil {
+			RespondError(ctx, w, err)
+			return
+		}
+
+		if errs := validate.Struct(&req, bindName, "", "."); len(errs) > 0 {
+			RespondError(ctx, w, errs)
+			return
+		}
+
+		resp, err := fn(ctx, req)
+		if err != nil {
+			RespondError(ctx, w, err)
+			return
+		}
+
+		status := http.StatusOK
+		if sc, is := any(resp).(StatusCoder); is {
+			status = sc.StatusCode()
+		}
+
+		if status == http.StatusNoContent {
+			w.WriteHeader(status)
+			return
+		}
+
+		Respond(ctx, w, r, status, resp)
+	}
+}
+
+// bindName names the request fields in validation errors after their source, e.g. query.limit or body.name.
+func bindName(sf reflect.StructField) string {
+	for _, tag := range []string{tagPath, tagQuery, tagHeader} {
+		if name := sf.Tag.Get(tag); name != "" {
+			return tag + "." + name
+		}
+	}
+	if _, isBody := sf.Tag.Lookup(tagBody); isBody {
+		return tagBody
+	}
+
+	return validate.TagName("json")(sf)
+}
+
+// Bind fills v, a pointer to struct, from r according to the tags of its fields:
+//   - `path:"name"` the chi URL param name.
+//   - `query:"name"` the query parameter name (every value, for slice fields).
+//   - `header:"Name"` the header Name (every value, for slice fields).
+//   - `body:""` the request body, decoded with the codec of its Content-Type (see Decode).
+//     A pointer body field is left nil when the request has no body.
+//
+// Missing parameters take the value of the `default` tag, if any. Parameters can be strings, booleans, numbers,
+// durations, encoding.TextUnmarshaler (e.g. time.Time), pointers (nil when missing) or slices of them.
+// Embedded structs are bound too. Invalid parameters are reported together in a 400 *APIError; parameters of any
+// other type fail every request with an error, without reading r.
+func Bind(r *http.Request, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind: %T is not a pointer to struct", v)
+	}
+
+	if err := checkBindable(rv.Elem().Type()); err != nil {
+		return err
+	}
+
+	var fieldErrs []FieldError
+	if err := bindStruct(r, rv.Elem(), &fieldErrs); err != nil {
+		return err
+	}
+
+	if len(fieldErrs) > 0 {
+		return &APIError{Status: http.StatusBadRequest, Detail: "The request parameters are invalid.", Errors: fieldErrs}
+	}
+
+	return nil
+}
+
+func bindStruct(r *http.Request, rv reflect.Value, fieldErrs *[]FieldError) error {
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := bindStruct(r, fv, fieldErrs); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		if _, isBody := sf.Tag.Lookup(tagBody); isBody {
+			if err := bindBody(r, fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		source, name, values := paramValues(r, sf)
+		if source == "" {
+			continue
+		}
+
+		if len(values) == 0 {
+			d, hasDefault := sf.Tag.Lookup("default")
+			if !hasDefault {
+				continue
+			}
+			values = []string{d}
+		}
+
+		if err := setParam(fv, values); err != nil {
+			*fieldErrs = append(*fieldErrs, FieldError{Field: source + "." + name, Rule: "type", Message: err.Error()})
+		}
+	}
+
+	return nil
+}
+
+//nolint:gochecknoglobals
+var bindableTypes sync.Map // reflect.Type to the error of checkBindable.
+
+// checkBindable returns an error if rt is not a struct or has parameters of a type Bind does not support. The result
+// is computed once per type.
+func checkBindable(rt reflect.Type) error {
+	if err, checked := bindableTypes.Load(rt); checked {
+		err, _ := err.(error)
+		return err
+	}
+
+	var err error
+	if rt.Kind() != reflect.Struct {
+		err = fmt.Errorf("bind: %s is not a struct", rt)
+	} else {
+		err = checkStruct(rt)
+	}
+	bindableTypes.Store(rt, err)
+
+	return err
+}
+
+func checkStruct(rt reflect.Type) error {
+	for i := range rt.NumField() {
+		sf := rt.Field(i)
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := checkStruct(sf.Type); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !sf.IsExported() || (sf.Tag.Get(tagPath) == "" && sf.Tag.Get(tagQuery) == "" && sf.Tag.Get(tagHeader) == "") {
+			continue
+		}
+
+		t := sf.Type
+		if t.Kind() == reflect.Slice && !isTextUnmarshaler(t) {
+			t = t.Elem()
+		}
+		if !isSupportedValue(t) {
+			return fmt.Errorf("bind: unsupported parameter type %s of %s.%s", sf.Type, rt, sf.Name)
+		}
+	}
+
+	return nil
+}
+
+// isSupportedValue reports whether setValue can set values of t.
+func isSupportedValue(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		return isSupportedValue(t.Elem())
+	}
+
+	if isTextUnmarshaler(t) || t == durationType {
+		return true
+	}
+
+	switch t.Kind() { //nolint:exhaustive
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// paramValues returns the source (path, query or header), the name and the values of the parameter of sf.
+func paramValues(r *http.Request, sf reflect.StructField) (string, string, []string) {
+	if name := sf.Tag.Get(tagPath); name != "" {
+		if v := chi.URLParam(r, name); v != "" {
+			return tagPath, name, []string{v}
+		}
+		return tagPath, name, nil
+	}
+
+	if name := sf.Tag.Get(tagQuery); name != "" {
+		return tagQuery, name, r.URL.Query()[name]
+	}
+
+	if name := sf.Tag.Get(tagHeader); name != "" {
+		return tagHeader, name, r.Header.Values(name)
+	}
+
+	return "", "", nil
+}
+
+func bindBody(r *http.Request, fv reflect.Value) error {
+	if fv.Kind() == reflect.Pointer {
+		if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+			return nil
+		}
+		fv.Set(reflect.New(fv.Type().Elem()))
+		return Decode(r, fv.Interface())
+	}
+
+	return Decode(r, fv.Addr().Interface())
+}
+
+// setParam sets fv from the parameter values; non slice fields take the first one.
+func setParam(fv reflect.Value, values []string) error {
+	if fv.Kind() == reflect.Slice && !isTextUnmarshaler(fv.Type()) {
+		sl := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := setValue(sl.Index(i), s); err != nil {
+				return err
+			}
+		}
+		fv.Set(sl)
+		return nil
+	}
+
+	return setValue(fv, values[0])
+}
+
+//nolint:gochecknoglobals
+var (
+	durationType        = reflect.TypeFor[time.Duration]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+func isTextUnmarshaler(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+func setValue(fv reflect.Value, s string) error {
+	if fv.Kind() == reflect.Pointer {
+		p := reflect.New(fv.Type().Elem())
+		if err := setValue(p.Elem(), s); err != nil {
+			return err
+		}
+		fv.Set(p)
+		return nil
+	}
+
+	if isTextUnmarshaler(fv.Type()) {
+		tu, _ := fv.Addr().Interface().(encoding.TextUnmarshaler)
+		if err := tu.UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("must be a valid %s", fv.Type())
+		}
+		return nil
+	}
+
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return errors.New("must be a valid duration")
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() { //nolint:exhaustive
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return errors.New("must be a non negative integer")
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		fv.SetFloat(f)
+	default:
+		// unreachable, the types are checked by checkBindable.
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+
+	return nil
+}
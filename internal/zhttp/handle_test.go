@@ -0,0 +1,197 @@
+package zhttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type handleTestPage struct {
+	Limit  int `query:"limit"  default:"10" validate:"min=1,max=100"`
+	Offset int `query:"offset"`
+}
+
+type handleTestBody struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type handleTestRequest struct {
+	handleTestPage
+
+	ID      int64          `path:"id"         validate:"min=1"`
+	Tags    []string       `query:"tag"`
+	Since   *time.Time     `query:"since"`
+	Timeout time.Duration  `query:"timeout"   default:"1s"`
+	TraceID string         `header:"X-Trace-Id"`
+	Body    handleTestBody `body:""`
+}
+
+type handleTestResponse struct {
+	Request handleTestRequest `json:"request"`
+}
+
+func (handleTestResponse) StatusCode() int { return http.StatusCreated }
+
+func TestHandle(t *testing.T) {
+	t.Parallel()
+
+	errNotFound := NewAPIError(http.StatusNotFound, "no such item")
+
+	router := chi.NewRouter()
+	router.Post("/items/{id}", Handle(func(_ context.Context, req handleTestRequest) (handleTestResponse, error) {
+		if req.ID == 404 {
+			return handleTestResponse{}, errNotFound
+		}
+		return handleTestResponse{Request: req}, nil
+	}))
+	router.Delete("/items/{id}", Handle(func(_ context.Context, _ handleTestPage) (NoContent, error) {
+		return NoContent{}, nil
+	}))
+
+	do := func(t *testing.T, method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequestWithContext(t.Context(), method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Trace-Id", "abc")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("bind", func(t *testing.T) {
+		t.Parallel()
+
+		resp := do(t, http.MethodPost, "/items/7?tag=a&tag=b&offset=5&since=2024-01-02T03:04:05Z", `{"name":"yoda"}`)
+		require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+		got := handleTestResponse{}
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+		since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		assert.Equal(t, handleTestRequest{
+			handleTestPage: handleTestPage{Limit: 10, Offset: 5},
+			ID:             7,
+			Tags:           []string{"a", "b"},
+			Since:          &since,
+			Timeout:        time.Second,
+			TraceID:        "abc",
+			Body:           handleTestBody{Name: "yoda"},
+		}, got.Request)
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		t.Parallel()
+
+		resp := do(t, http.MethodPost, "/items/x?limit=y&since=yesterday", `{"name":"yoda"}`)
+		require.Equal(t, http.StatusBadRequest, resp.Code)
+
+		problem := APIError{}
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &problem))
+		assert.Equal(t, []FieldError{
+			{Field: "query.limit", Rule: "type", Message: "must be an integer"},
+			{Field: "path.id", Rule: "type", Message: "must be an integer"},
+			{Field: "query.since", Rule: "type", Message: "must be a valid time.Time"},
+		}, problem.Errors)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		t.Parallel()
+
+		resp := do(t, http.MethodPost, "/items/7?limit=1000", `{"name":""}`)
+		require.Equal(t, http.StatusBadRequest, resp.Code)
+
+		problem := APIError{}
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &problem))
+		fields := make([]string, 0, len(problem.Errors))
+		for _, fe := range problem.Errors {
+			fields = append(fields, fe.Field)
+		}
+		assert.ElementsMatch(t, []string{"query.limit", "body.name"}, fields)
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		t.Parallel()
+
+		resp := do(t, http.MethodPost, "/items/7", `{"name":`)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Equal(t, ProblemContentType, resp.Header().Get("Content-Type"))
+	})
+
+	t.Run("handler error", func(t *testing.T) {
+		t.Parallel()
+
+		resp := do(t, http.MethodPost, "/items/404", `{"name":"yoda"}`)
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+		assert.Contains(t, resp.Body.String(), "no such item")
+	})
+
+	t.Run("no content", func(t *testing.T) {
+		t.Parallel()
+
+		resp := do(t, http.MethodDelete, "/items/7", "")
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		assert.Empty(t, resp.Body.String())
+	})
+}
+
+func TestBindOptionalBody(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		Body *handleTestBody `body:""`
+	}
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/", nil)
+	got := request{}
+	require.NoError(t, Bind(req, &got))
+	assert.Nil(t, got.Body)
+
+	req = httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/", strings.NewReader(`{"name":"yoda"}`))
+	req.Header.Set("Content-Type", "application/json")
+	require.NoError(t, Bind(req, &got))
+	require.NotNil(t, got.Body)
+	assert.Equal(t, "yoda", got.Body.Name)
+
+	assert.Error(t, Bind(req, got))
+}
+
+func TestBindUnsupportedType(t *testing.T) {
+	t.Parallel()
+
+	type embedded struct {
+		Filter map[string]string `query:"filter"`
+	}
+	type request struct {
+		embedded
+
+		Limit int `query:"limit"`
+	}
+	type supported struct {
+		IDs     []*int64       `query:"id"`
+		Since   []time.Time    `query:"since"`
+		Timeout *time.Duration `header:"X-Timeout"`
+		Ignored chan int
+		Body    map[string]any `body:""`
+	}
+
+	require.NotPanics(t, func() { Handle(func(context.Context, supported) (NoContent, error) { return NoContent{}, nil }) })
+
+	// a route of an unsupported type fails when it is set up, not per request.
+	assert.PanicsWithError(t, "bind: unsupported parameter type map[string]string of zhttp.embedded.Filter", func() {
+		Handle(func(context.Context, request) (NoContent, error) { return NoContent{}, nil })
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/?limit=1&filter=x", nil)
+	got := request{}
+	err := Bind(req, &got)
+	require.Error(t, err)
+	assert.NotErrorAs(t, err, new(*APIError))
+	assert.Zero(t, got.Limit)
+}
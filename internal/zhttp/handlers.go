@@ -4,8 +4,17 @@ import (
 	"net/http"
 
 	"github.com/moukoublen/goboilerplate/build"
+	"github.com/moukoublen/goboilerplate/internal/config"
 )
 
 func AboutHandler(w http.ResponseWriter, r *http.Request) {
-	RespondJSON(r.Context(), w, http.StatusOK, build.GetInfo())
+	Respond(r.Context(), w, r, http.StatusOK, build.GetInfo(), WithETag(r))
+}
+
+// ConfigExplainHandler renders every effective config key with its value and the layer it came from.
+// Secret values are redacted by explain.
+func ConfigExplainHandler(explain func() []config.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		RespondJSON(r.Context(), w, http.StatusOK, explain())
+	}
 }
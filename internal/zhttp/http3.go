@@ -0,0 +1,78 @@
+package zhttp
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// ListenerHTTP3 is the name of the udp socket of HTTP/3.
+const ListenerHTTP3 = "http3"
+
+type HTTP3Config struct {
+	Enabled bool  `koanf:"enabled" default:"false"                              desc:"Serves HTTP/3 (QUIC) next to the public TLS listener and advertises it with the Alt-Svc header. It requires http.tls.enabled."`
+	Port    int64 `koanf:"port"    default:"0"     validate:"min=0,max=65535" desc:"The UDP port of HTTP/3. 0 means the same as http.port."`
+}
+
+// HTTP3Address returns the udp address of HTTP/3, which binds to the IP of the public server.
+func (c Config) HTTP3Address() (string, error) {
+	a := c.Address()
+	if a.Network != "tcp" {
+		return "", errors.New("http3 requires the public server to listen to an ip address")
+	}
+
+	if c.HTTP3.Port == 0 {
+		return a.Addr, nil
+	}
+
+	host, _, err := net.SplitHostPort(a.Addr)
+	if err != nil {
+		return "", err
+	}
+
+	return net.JoinHostPort(host, strconv.FormatInt(c.HTTP3.Port, 10)), nil
+}
+
+// StartHTTP3 creates and runs an HTTP/3 server over the udp socket conn in a separate go routine.
+// The TLS config is shared with the TLS listener (certificates reload on both).
+// Any error produced by Serve will be sent to fatalErrCh.
+func StartHTTP3(conn net.PacketConn, handler http.Handler, opts ServerOptions, fatalErrCh chan<- error) (*http3.Server, error) {
+	if opts.TLSConfig == nil {
+		return nil, errors.New("http3 requires tls")
+	}
+
+	server := &http3.Server{
+		Handler:        handler,
+		TLSConfig:      http3.ConfigureTLSConfig(opts.TLSConfig),
+		MaxHeaderBytes: opts.MaxHeaderBytes,
+		IdleTimeout:    opts.IdleTimeout,
+	}
+	if udpAddr, isUDP := conn.LocalAddr().(*net.UDPAddr); isUDP {
+		server.Port = udpAddr.Port
+	}
+
+	go func() {
+		if err := server.Serve(conn); err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+			fatalErrCh <- err
+		}
+	}()
+
+	return server, nil
+}
+
+// AltSvc advertises the HTTP/3 server in the Alt-Svc header of every response (RFC 7838),
+// so clients can switch from the TLS listener to HTTP/3.
+func AltSvc(server *http3.Server) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// HTTP/3 responses need no advertisement.
+			if r.ProtoMajor < 3 {
+				_ = server.SetQUICHeaders(w.Header())
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
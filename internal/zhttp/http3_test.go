@@ -0,0 +1,109 @@
+package zhttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTP3Address(t *testing.T) {
+	t.Parallel()
+
+	addr, err := Config{ListenerConfig: ListenerConfig{IP: "0.0.0.0", Port: 8443}}.HTTP3Address()
+	require.NoError(t, err)
+	assert.Equal(t, "0.0.0.0:8443", addr)
+
+	addr, err = Config{ListenerConfig: ListenerConfig{IP: "::1", Port: 8443}, HTTP3: HTTP3Config{Port: 443}}.HTTP3Address()
+	require.NoError(t, err)
+	assert.Equal(t, "[::1]:443", addr)
+
+	_, err = Config{ListenerConfig: ListenerConfig{IP: "unix:///run/app.sock"}}.HTTP3Address()
+	require.Error(t, err)
+}
+
+func TestHTTP3(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ca := newTestCert(t, 1, "test ca", nil, true)
+	certFile, keyFile := writeTestCert(t, dir, newTestCert(t, 10, "server", &ca, false))
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, ca.certPEM, 0o600))
+
+	tlsConf, err := NewTLSConfig(t.Context(), TLSConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.2"})
+	require.NoError(t, err)
+	opts := ServerOptions{ReadHeaderTimeout: time.Second, TLSConfig: tlsConf}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Proto)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	conn, err := net.ListenPacket("udp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	require.NoError(t, err)
+
+	servers := &Servers{}
+	fatalErrCh := make(chan error, 2)
+	h3, err := servers.StartHTTP3(t.Context(), ListenerHTTP3, conn, handler, opts, fatalErrCh)
+	require.NoError(t, err)
+	servers.Start(t.Context(), ListenerPublic, ln, AltSvc(h3)(handler), opts, fatalErrCh)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	url := "https://127.0.0.1:" + strconv.Itoa(port)
+
+	// TLS listener, advertising HTTP/3.
+	tlsClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}, ForceAttemptHTTP2: true}}
+	body, header := requestBody(t, tlsClient, url)
+	assert.Equal(t, "HTTP/2.0", body)
+	assert.Equal(t, `h3=":`+strconv.Itoa(port)+`"; ma=2592000`, header.Get("Alt-Svc"))
+
+	// HTTP/3, sharing the handler.
+	h3Transport := &http3.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS13}}
+	defer h3Transport.Close()
+	body, header = requestBody(t, &http.Client{Transport: h3Transport}, url)
+	assert.Equal(t, "HTTP/3.0", body)
+	assert.Empty(t, header.Get("Alt-Svc"))
+
+	require.NoError(t, servers.Shutdown(t.Context()))
+	assert.Empty(t, fatalErrCh)
+}
+
+func TestHTTP3RequiresTLS(t *testing.T) {
+	t.Parallel()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = StartHTTP3(conn, http.NotFoundHandler(), ServerOptions{}, make(chan error, 1))
+	require.Error(t, err)
+}
+
+func requestBody(t *testing.T, client *http.Client, url string) (string, http.Header) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return string(b), resp.Header
+}
@@ -0,0 +1,294 @@
+package zhttp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+type JWTAuthConfig struct {
+	Enabled  bool          `koanf:"enabled"   default:"false"                   desc:"Accepts JWT bearer tokens, verified against the keys of jwks_file."`
+	JWKSFile string        `koanf:"jwks_file"                                   desc:"The JSON Web Key Set file of the keys that tokens are signed with (RSA, EC or Ed25519)."`
+	Issuer   string        `koanf:"issuer"                                      desc:"The expected iss claim. Empty accepts any issuer."`
+	Audience string        `koanf:"audience"                                    desc:"The audience that the aud claim must contain. Empty accepts any audience."`
+	Leeway   time.Duration `koanf:"leeway"    default:"1m"    validate:"min=0" desc:"The clock skew tolerated when checking the exp and nbf claims."`
+}
+
+// JWTAuthenticator verifies JWT bearer tokens (RFC 7519) signed with the asymmetric keys of a JWKS file. The sub
+// claim becomes the subject of the principal, the scope (or scp) claim its scopes and the roles claim its roles.
+type JWTAuthenticator struct {
+	keys     []jwk
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// jwk is a verification key of the JWKS file.
+type jwk struct {
+	id  string
+	alg string // empty when the key does not restrict it.
+	key crypto.PublicKey
+}
+
+func NewJWTAuthenticator(c JWTAuthConfig) (*JWTAuthenticator, error) {
+	if c.JWKSFile == "" {
+		return nil, errors.New("no jwks file is set")
+	}
+
+	b, err := os.ReadFile(c.JWKSFile)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := parseJWKS(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.JWKSFile, err)
+	}
+
+	return &JWTAuthenticator{keys: keys, issuer: c.Issuer, audience: c.Audience, leeway: c.Leeway, now: time.Now}, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return Principal{}, errNoCredentials
+	}
+
+	claims, err := a.verify(strings.TrimSpace(token))
+	if err != nil {
+		return Principal{}, fmt.Errorf("invalid token: %w", err)
+	}
+
+	p := Principal{Authenticator: AuthJWT, Claims: claims}
+	p.Subject, _ = claims["sub"].(string)
+	if p.Subject == "" {
+		return Principal{}, errors.New("invalid token: no sub claim")
+	}
+
+	if scope, is := claims["scope"].(string); is {
+		p.Scopes = strings.Fields(scope)
+	} else {
+		p.Scopes = claimStrings(claims["scp"])
+	}
+	p.Roles = claimStrings(claims["roles"])
+
+	return p, nil
+}
+
+func (a *JWTAuthenticator) Challenge() string { return "Bearer" }
+
+// verify checks the signature and the registered claims of the compact serialized token and returns its claims.
+func (a *JWTAuthenticator) verify(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %w", err)
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, k := range a.keys {
+		if (header.Kid != "" && k.id != header.Kid) || (k.alg != "" && k.alg != header.Alg) {
+			continue
+		}
+		if verifyJWS(header.Alg, k.key, signed, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("the %s signature does not match any key", header.Alg)
+	}
+
+	claims := map[string]any{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+
+	now := a.now()
+	exp, hasExp := claims["exp"].(float64)
+	if !hasExp {
+		return nil, errors.New("no exp claim")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(a.leeway)) {
+		return nil, errors.New("expired")
+	}
+	if nbf, has := claims["nbf"].(float64); has && now.Add(a.leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("not valid yet")
+	}
+	if a.issuer != "" && claims["iss"] != a.issuer {
+		return nil, fmt.Errorf("unexpected issuer %v", claims["iss"])
+	}
+	if a.audience != "" && !slices.Contains(claimStrings(claims["aud"]), a.audience) {
+		return nil, fmt.Errorf("unexpected audience %v", claims["aud"])
+	}
+
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// claimStrings returns a claim that is either a string or an array of strings (e.g. aud) as a slice.
+func claimStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		s := make([]string, 0, len(v))
+		for _, e := range v {
+			if es, is := e.(string); is {
+				s = append(s, es)
+			}
+		}
+		return s
+	default:
+		return nil
+	}
+}
+
+// verifyJWS verifies the signature of the alg (RFC 7518) over signed. Only asymmetric algorithms are supported,
+// and the type of key must match the alg.
+func verifyJWS(alg string, key crypto.PublicKey, signed, sig []byte) bool {
+	var h crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		h = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		h = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		h = crypto.SHA512
+	case "EdDSA":
+		k, is := key.(ed25519.PublicKey)
+		return is && ed25519.Verify(k, signed, sig)
+	default: // none and the symmetric HS* included.
+		return false
+	}
+
+	hasher := h.New()
+	hasher.Write(signed)
+	digest := hasher.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg[0] == 'P' {
+			return rsa.VerifyPSS(k, h, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+		return alg[0] == 'R' && rsa.VerifyPKCS1v15(k, h, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		// each ES alg is bound to a curve, and the signature is the fixed size r || s.
+		bits := map[string]int{"ES256": 256, "ES384": 384, "ES512": 521}[alg]
+		size := (bits + 7) / 8
+		if k.Curve.Params().BitSize != bits || len(sig) != 2*size {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	default:
+		return false
+	}
+}
+
+// parseJWKS parses the verification keys of a JSON Web Key Set (RFC 7517). Keys meant for encryption are skipped.
+func parseJWKS(b []byte) ([]jwk, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make([]jwk, 0, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		b64 := func(s string) []byte {
+			d, err := base64.RawURLEncoding.DecodeString(s)
+			if err != nil {
+				return nil
+			}
+			return d
+		}
+
+		var pub crypto.PublicKey
+		switch {
+		case k.Kty == "RSA" && len(b64(k.N)) > 0 && len(b64(k.E)) > 0:
+			e := new(big.Int).SetBytes(b64(k.E))
+			if !e.IsInt64() || e.Int64() > 1<<31-1 {
+				return nil, fmt.Errorf("key %d: invalid RSA exponent", i)
+			}
+			pub = &rsa.PublicKey{N: new(big.Int).SetBytes(b64(k.N)), E: int(e.Int64())}
+		case k.Kty == "EC":
+			curve := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}[k.Crv]
+			if curve == nil {
+				return nil, fmt.Errorf("key %d: unsupported curve %q", i, k.Crv)
+			}
+			// the uncompressed point encoding; ParseUncompressedPublicKey checks that the point is on the curve.
+			size := (curve.Params().BitSize + 7) / 8
+			x, y := b64(k.X), b64(k.Y)
+			if len(x) != size || len(y) != size {
+				return nil, fmt.Errorf("key %d: invalid EC point", i)
+			}
+			ec, err := ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+			if err != nil {
+				return nil, fmt.Errorf("key %d: %w", i, err)
+			}
+			pub = ec
+		case k.Kty == "OKP" && k.Crv == "Ed25519" && len(b64(k.X)) == ed25519.PublicKeySize:
+			pub = ed25519.PublicKey(b64(k.X))
+		default:
+			return nil, fmt.Errorf("key %d: unsupported or invalid %s key", i, k.Kty)
+		}
+
+		keys = append(keys, jwk{id: k.Kid, alg: k.Alg, key: pub})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,239 @@
+package zhttp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jwtTestKeys struct {
+	rsa     *rsa.PrivateKey
+	ec256   *ecdsa.PrivateKey
+	ec384   *ecdsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+}
+
+func newJWTTestKeys(t *testing.T) jwtTestKeys {
+	t.Helper()
+
+	var k jwtTestKeys
+	var err error
+	k.rsa, err = rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	k.ec256, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	k.ec384, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, k.ed25519, err = ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return k
+}
+
+func (k jwtTestKeys) jwks(t *testing.T) string {
+	t.Helper()
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	ecKey := func(kid string, pub ecdsa.PublicKey) map[string]string {
+		b, err := pub.Bytes()
+		require.NoError(t, err)
+		size := (len(b) - 1) / 2
+		return map[string]string{"kty": "EC", "kid": kid, "crv": pub.Params().Name, "x": b64(b[1 : 1+size]), "y": b64(b[1+size:])}
+	}
+
+	set := map[string]any{"keys": []any{
+		map[string]string{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(k.rsa.N.Bytes()), "e": b64(big.NewInt(int64(k.rsa.E)).Bytes())},
+		ecKey("ec256", k.ec256.PublicKey),
+		ecKey("ec384", k.ec384.PublicKey),
+		map[string]string{"kty": "OKP", "kid": "ed", "alg": "EdDSA", "crv": "Ed25519", "x": b64(k.ed25519.Public().(ed25519.PublicKey))},
+		map[string]string{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	}}
+	b, err := json.Marshal(set)
+	require.NoError(t, err)
+
+	return writeTestFile(t, "jwks.json", string(b))
+}
+
+func (k jwtTestKeys) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := func(h crypto.Hash) []byte {
+		hasher := h.New()
+		hasher.Write([]byte(signed))
+		return hasher.Sum(nil)
+	}
+	ecSign := func(key *ecdsa.PrivateKey, h crypto.Hash) []byte {
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest(h))
+		require.NoError(t, err)
+		size := (key.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+		return sig
+	}
+
+	var sig []byte
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest(crypto.SHA256))
+	case "PS384":
+		sig, err = rsa.SignPSS(rand.Reader, k.rsa, crypto.SHA384, digest(crypto.SHA384), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case "ES256":
+		sig = ecSign(k.ec256, crypto.SHA256)
+	case "ES384":
+		sig = ecSign(k.ec384, crypto.SHA384)
+	case "EdDSA":
+		sig = ed25519.Sign(k.ed25519, []byte(signed))
+	case "none":
+	}
+	require.NoError(t, err)
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	keys := newJWTTestKeys(t)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	a, err := NewJWTAuthenticator(JWTAuthConfig{JWKSFile: keys.jwks(t), Issuer: "https://issuer", Audience: "api", Leeway: time.Minute})
+	require.NoError(t, err)
+	a.now = func() time.Time { return now }
+
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{"sub": "yoda", "iss": "https://issuer", "aud": []string{"web", "api"}, "exp": now.Add(time.Hour).Unix()}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := map[string]struct {
+		token         string
+		expectedError bool
+		expected      Principal
+	}{
+		"RS256": {
+			token:    keys.sign(t, "RS256", "rsa", claims(map[string]any{"scope": "read write", "roles": []string{"admin"}})),
+			expected: Principal{Subject: "yoda", Scopes: []string{"read", "write"}, Roles: []string{"admin"}},
+		},
+		"PS384": {
+			token:    keys.sign(t, "PS384", "rsa", claims(map[string]any{"scp": []string{"read"}})),
+			expected: Principal{Subject: "yoda", Scopes: []string{"read"}},
+		},
+		"ES256 without kid": {
+			token:    keys.sign(t, "ES256", "", claims(map[string]any{"roles": "admin"})),
+			expected: Principal{Subject: "yoda", Roles: []string{"admin"}},
+		},
+		"ES384": {
+			token:    keys.sign(t, "ES384", "ec384", claims(nil)),
+			expected: Principal{Subject: "yoda"},
+		},
+		"EdDSA": {
+			token:    keys.sign(t, "EdDSA", "ed", claims(map[string]any{"aud": "api"})),
+			expected: Principal{Subject: "yoda"},
+		},
+		"expired within leeway": {
+			token:    keys.sign(t, "ES256", "ec256", claims(map[string]any{"exp": now.Add(-30 * time.Second).Unix()})),
+			expected: Principal{Subject: "yoda"},
+		},
+		"expired":             {token: keys.sign(t, "ES256", "ec256", claims(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()})), expectedError: true},
+		"no exp":              {token: keys.sign(t, "ES256", "ec256", claims(map[string]any{"exp": nil})), expectedError: true},
+		"not valid yet":       {token: keys.sign(t, "ES256", "ec256", claims(map[string]any{"nbf": now.Add(time.Hour).Unix()})), expectedError: true},
+		"wrong issuer":        {token: keys.sign(t, "ES256", "ec256", claims(map[string]any{"iss": "https://other"})), expectedError: true},
+		"wrong audience":      {token: keys.sign(t, "ES256", "ec256", claims(map[string]any{"aud": "web"})), expectedError: true},
+		"no sub":              {token: keys.sign(t, "ES256", "ec256", claims(map[string]any{"sub": nil})), expectedError: true},
+		"wrong kid":           {token: keys.sign(t, "ES256", "ec384", claims(nil)), expectedError: true},
+		"alg of another key":  {token: keys.sign(t, "EdDSA", "rsa", claims(nil)), expectedError: true},
+		"alg none":            {token: keys.sign(t, "none", "", claims(nil)), expectedError: true},
+		"malformed":           {token: "a.b", expectedError: true},
+		"tampered claims":     {token: tamperJWT(t, keys.sign(t, "RS256", "rsa", claims(nil))), expectedError: true},
+		"not base64 segments": {token: "!.!.!", expectedError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+
+			got, err := a.Authenticate(req)
+			if tc.expectedError {
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, errNoCredentials)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "yoda", got.Claims["sub"])
+			got.Claims = nil
+			tc.expected.Authenticator = AuthJWT
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+
+	// other schemes are not credentials of the authenticator.
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Basic eW9kYTpzZWNyZXQ=")
+	_, err = a.Authenticate(req)
+	assert.ErrorIs(t, err, errNoCredentials)
+}
+
+// tamperJWT replaces the claims of token, keeping its signature.
+func tamperJWT(t *testing.T, token string) string {
+	t.Helper()
+
+	payload, err := json.Marshal(map[string]any{"sub": "vader", "exp": time.Now().Add(time.Hour).Unix()})
+	require.NoError(t, err)
+	parts := strings.Split(token, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+
+	return strings.Join(parts, ".")
+}
+
+func TestParseJWKS(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"not json":          `{`,
+		"no keys":           `{"keys":[]}`,
+		"only encryption":   `{"keys":[{"kty":"RSA","use":"enc","n":"AQAB","e":"AQAB"}]}`,
+		"symmetric":         `{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`,
+		"unsupported curve": `{"keys":[{"kty":"EC","crv":"P-192","x":"AA","y":"AA"}]}`,
+		"point off curve":   `{"keys":[{"kty":"EC","crv":"P-256","x":"` + base64.RawURLEncoding.EncodeToString(make([]byte, 32)) + `","y":"` + base64.RawURLEncoding.EncodeToString(make([]byte, 32)) + `"}]}`,
+	}
+
+	for name, jwks := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parseJWKS([]byte(jwks))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package zhttp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	t.Parallel()
+
+	handler := MaxBodySize(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := map[string]struct {
+		body          string
+		contentLength int64
+		expected      int
+	}{
+		"within limit":                 {body: "1234", contentLength: 4, expected: http.StatusOK},
+		"declared length over limit":   {body: "12345", contentLength: 5, expected: http.StatusRequestEntityTooLarge},
+		"undeclared length over limit": {body: "12345", contentLength: -1, expected: http.StatusRequestEntityTooLarge},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/", strings.NewReader(tc.body))
+			req.ContentLength = tc.contentLength
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			assert.Equal(t, tc.expected, resp.Code)
+		})
+	}
+}
+
+func TestLimitListener(t *testing.T) {
+	t.Parallel()
+
+	raw, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln := newLimitListener(raw, 1)
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	for range 2 {
+		c, err := net.Dial("tcp", raw.Addr().String())
+		require.NoError(t, err)
+		defer c.Close()
+	}
+
+	first := <-accepted
+	select {
+	case <-accepted:
+		t.Fatal("second connection accepted while the limit is reached")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// closing the first connection frees the slot.
+	require.NoError(t, first.Close())
+	select {
+	case c := <-accepted:
+		require.NoError(t, c.Close())
+	case <-time.After(5 * time.Second):
+		t.Fatal("second connection was never accepted")
+	}
+
+	// close unblocks Accept.
+	require.NoError(t, ln.Close())
+	_, open := <-accepted
+	assert.False(t, open)
+}
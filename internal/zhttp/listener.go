@@ -0,0 +1,372 @@
+package zhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+)
+
+// Env vars of the listening sockets that are passed to a process.
+// The systemd socket activation protocol (sd_listen_fds) is used both for systemd and for graceful restarts.
+// On graceful restart LISTEN_PID cannot be known beforehand, so the parent pid is passed in envListenParentPID instead.
+const (
+	envListenFDs       = "LISTEN_FDS"
+	envListenFDNames   = "LISTEN_FDNAMES"
+	envListenPID       = "LISTEN_PID"
+	envListenParentPID = "ZHTTP_LISTEN_PPID"
+
+	listenFDsStart = 3 // SD_LISTEN_FDS_START
+)
+
+type namedListener struct {
+	name string
+	ln   net.Listener   // stream sockets (tcp, unix).
+	pc   net.PacketConn // packet sockets (udp, e.g. http3).
+}
+
+func (nl namedListener) addr() net.Addr {
+	if nl.pc != nil {
+		return nl.pc.LocalAddr()
+	}
+
+	return nl.ln.Addr()
+}
+
+func (nl namedListener) close() error {
+	if nl.pc != nil {
+		return nl.pc.Close()
+	}
+
+	return nl.ln.Close()
+}
+
+func (nl namedListener) file() (*os.File, error) {
+	var s any = nl.ln
+	if nl.pc != nil {
+		s = nl.pc
+	}
+
+	fl, isFiler := s.(interface{ File() (*os.File, error) })
+	if !isFiler {
+		return nil, fmt.Errorf("%T cannot be handed off", s)
+	}
+
+	return fl.File()
+}
+
+// Listeners opens the listening sockets of the http servers, or takes them over when they are inherited
+// (systemd socket activation or graceful restart). It can also hand them off to a new process (see Restart).
+type Listeners struct {
+	mu        sync.Mutex
+	inherited []namedListener
+	active    []namedListener
+	parentPID int  // > 0 when the sockets were inherited through a graceful restart.
+	handoff   bool // a child process of Restart is taking over; it is cleared if the child exits.
+}
+
+// errRestartInProgress is returned by Restart while a previous one is still handing off the listeners.
+var errRestartInProgress = errors.New("graceful restart already in progress")
+
+// NewListeners collects the inherited listening sockets (if any).
+func NewListeners() (*Listeners, error) {
+	inherited, parentPID, err := inheritListeners(os.Getenv, os.Getpid(), os.Getppid(), os.NewFile)
+	for _, e := range []string{envListenFDs, envListenFDNames, envListenPID, envListenParentPID} {
+		_ = os.Unsetenv(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Listeners{inherited: inherited, parentPID: parentPID}, nil
+}
+
+// inheritListeners parses the LISTEN_FDS protocol. It returns the inherited listeners and the parent pid when
+// the sockets were handed off by a graceful restart.
+func inheritListeners(getenv func(string) string, pid, ppid int, newFile func(uintptr, string) *os.File) ([]namedListener, int, error) {
+	fdsStr := getenv(envListenFDs)
+	if fdsStr == "" {
+		return nil, 0, nil
+	}
+
+	parentPID := 0
+	switch {
+	case getenv(envListenPID) == strconv.Itoa(pid): // systemd
+	case getenv(envListenParentPID) == strconv.Itoa(ppid) && ppid > 1: // graceful restart
+		parentPID = ppid
+	default: // not meant for this process.
+		return nil, 0, nil
+	}
+
+	n, err := strconv.Atoi(fdsStr)
+	if err != nil || n < 0 {
+		return nil, 0, fmt.Errorf("invalid %s value %q", envListenFDs, fdsStr)
+	}
+
+	names := strings.Split(getenv(envListenFDNames), ":")
+
+	listeners := make([]namedListener, 0, n)
+	for i := range n {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+
+		f := newFile(uintptr(listenFDsStart+i), name)
+		nl, err := fileListener(name, f)
+		_ = f.Close() // FileListener / FilePacketConn dup the fd.
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.close()
+			}
+			return nil, 0, fmt.Errorf("inherited fd %d (%s): %w", listenFDsStart+i, name, err)
+		}
+
+		listeners = append(listeners, nl)
+	}
+
+	return listeners, parentPID, nil
+}
+
+// fileListener returns the stream or the packet socket of f.
+func fileListener(name string, f *os.File) (namedListener, error) {
+	ln, err := net.FileListener(f)
+	if err == nil {
+		return namedListener{name: name, ln: ln}, nil
+	}
+
+	pc, pcErr := net.FilePacketConn(f)
+	if pcErr != nil {
+		return namedListener{}, err
+	}
+
+	return namedListener{name: name, pc: pc}, nil
+}
+
+// Listen returns the inherited listener with the given name (or, if there is none, the one bound to addr).
+// When nothing is inherited, it opens a new listener on addr.
+func (l *Listeners) Listen(name string, addr Address) (net.Listener, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var ln net.Listener
+	if nl, found := l.takeInherited(name, addr.Addr, false); found {
+		ln = nl.ln
+	} else {
+		var err error
+		if ln, err = listen(addr); err != nil {
+			return nil, err
+		}
+	}
+
+	// a socket file handed off by a graceful restart is now owned (and removed on shutdown) by this process.
+	// Socket files of systemd remain owned by systemd.
+	if ul, isUnix := ln.(*net.UnixListener); isUnix && l.parentPID > 0 {
+		ul.SetUnlinkOnClose(true)
+	}
+
+	l.active = append(l.active, namedListener{name: name, ln: ln})
+
+	return ln, nil
+}
+
+// ListenPacket is the Listen of packet sockets: it returns the inherited udp socket with the given name
+// (or the one bound to addr), or opens a new one on addr.
+func (l *Listeners) ListenPacket(name, addr string) (net.PacketConn, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var pc net.PacketConn
+	if nl, found := l.takeInherited(name, addr, true); found {
+		pc = nl.pc
+	} else {
+		var err error
+		if pc, err = net.ListenPacket("udp", addr); err != nil {
+			return nil, err
+		}
+	}
+
+	l.active = append(l.active, namedListener{name: name, pc: pc})
+
+	return pc, nil
+}
+
+func (l *Listeners) takeInherited(name, addr string, packet bool) (namedListener, bool) {
+	match := -1
+	for i, il := range l.inherited {
+		if (il.pc != nil) == packet && il.name == name {
+			match = i
+			break
+		}
+	}
+
+	if match == -1 {
+		for i, il := range l.inherited {
+			if (il.pc != nil) == packet && il.addr().String() == addr {
+				match = i
+				break
+			}
+		}
+	}
+
+	if match == -1 {
+		return namedListener{}, false
+	}
+
+	nl := l.inherited[match]
+	l.inherited = append(l.inherited[:match], l.inherited[match+1:]...)
+
+	return nl, true
+}
+
+// Restart re-executes the current binary (same args) passing every active listener to it.
+// The new process starts serving on the inherited sockets and then, through Ready, asks this process to shut down
+// gracefully, so no connection is dropped.
+// Only one restart hands off the listeners at a time: until the child process exits, which means that the handoff
+// failed, further restarts fail with errRestartInProgress.
+func (l *Listeners) Restart(ctx context.Context) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.handoff {
+		return 0, errRestartInProgress
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	files := make([]*os.File, 0, len(l.active))
+	names := make([]string, 0, len(l.active))
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+
+	for _, al := range l.active {
+		f, err := al.file()
+		if err != nil {
+			return 0, fmt.Errorf("listener %s: %w", al.name, err)
+		}
+		files = append(files, f)
+		names = append(names, al.name)
+	}
+
+	env := make([]string, 0, len(os.Environ())+3)
+	for _, e := range os.Environ() {
+		k, _, _ := strings.Cut(e, "=")
+		switch k {
+		case envListenFDs, envListenFDNames, envListenPID, envListenParentPID:
+			continue
+		}
+		env = append(env, e)
+	}
+	env = append(env,
+		envListenFDs+"="+strconv.Itoa(len(files)),
+		envListenFDNames+"="+strings.Join(names, ":"),
+		envListenParentPID+"="+strconv.Itoa(os.Getpid()),
+	)
+
+	cmd := exec.CommandContext(context.WithoutCancel(ctx), exe, os.Args[1:]...) //nolint:gosec
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files // ExtraFiles[i] becomes fd 3+i in the child.
+
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	// the socket files now belong to the child; the shutdown of this process must not remove them.
+	l.handoff = true
+	l.setUnlinkOnClose(false)
+
+	// the child outlives this process unless the handoff fails; then this process keeps its socket files and
+	// another restart may be tried.
+	go func() {
+		_ = cmd.Wait()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.handoff = false
+		l.setUnlinkOnClose(true)
+	}()
+
+	return cmd.Process.Pid, nil
+}
+
+// setUnlinkOnClose sets whether the socket files of the active unix listeners are removed when they are closed.
+func (l *Listeners) setUnlinkOnClose(unlink bool) {
+	for _, al := range l.active {
+		if ul, isUnix := al.ln.(*net.UnixListener); isUnix {
+			ul.SetUnlinkOnClose(unlink)
+		}
+	}
+}
+
+// Ready should be called once every server serves. If the sockets were inherited through a graceful restart,
+// it asks the parent process to shut down gracefully (and drain its in flight requests).
+func (l *Listeners) Ready() error {
+	if l.parentPID <= 0 {
+		return nil
+	}
+
+	return signalParentShutdown(l.parentPID)
+}
+
+// Close closes any inherited listener that was never taken.
+func (l *Listeners) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, il := range l.inherited {
+		_ = il.close()
+	}
+	l.inherited = nil
+}
+
+// RestartOnSignal restarts gracefully (see Restart) on SIGHUP or SIGUSR2, until ctx is done.
+func (l *Listeners) RestartOnSignal(ctx context.Context) error {
+	if len(restartSignals) == 0 {
+		return errors.ErrUnsupported
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, restartSignals...)
+
+	go func() {
+		defer signal.Stop(ch)
+		logger := zlog.GetFromContext(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-ch:
+				logger.InfoContext(ctx, "graceful restart", slog.String("signal", sig.String()))
+				pid, err := l.Restart(ctx)
+				if errors.Is(err, errRestartInProgress) {
+					logger.WarnContext(ctx, "graceful restart already in progress, signal ignored", slog.String("signal", sig.String()))
+					continue
+				}
+				if err != nil {
+					logger.ErrorContext(ctx, "graceful restart failed", zlog.Error(err))
+					continue
+				}
+				logger.InfoContext(ctx, "new process started, waiting for it to take over", slog.Int("pid", pid))
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package zhttp
+
+import (
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFDs returns the files of real tcp listeners as if they were inherited starting from fd 3.
+func fakeFDs(t *testing.T, n int) (func(uintptr, string) *os.File, []string) {
+	t.Helper()
+
+	files := map[uintptr]*os.File{}
+	addrs := make([]string, 0, n)
+	for i := range n {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		f, err := ln.(*net.TCPListener).File()
+		require.NoError(t, err)
+		require.NoError(t, ln.Close())
+
+		files[uintptr(listenFDsStart+i)] = f
+		addrs = append(addrs, ln.Addr().String())
+	}
+
+	return func(fd uintptr, _ string) *os.File { return files[fd] }, addrs
+}
+
+func TestInheritListeners(t *testing.T) {
+	t.Parallel()
+
+	const pid, ppid = 100, 50
+
+	tests := map[string]struct {
+		env               map[string]string
+		expectedNames     []string
+		expectedParentPID int
+		expectedErr       bool
+	}{
+		"nothing inherited": {
+			env: map[string]string{},
+		},
+		"systemd": {
+			env:           map[string]string{envListenFDs: "2", envListenFDNames: "public:admin", envListenPID: strconv.Itoa(pid)},
+			expectedNames: []string{"public", "admin"},
+		},
+		"graceful restart": {
+			env:               map[string]string{envListenFDs: "1", envListenFDNames: "http", envListenParentPID: strconv.Itoa(ppid)},
+			expectedNames:     []string{"http"},
+			expectedParentPID: ppid,
+		},
+		"meant for another process": {
+			env: map[string]string{envListenFDs: "1", envListenPID: "1234"},
+		},
+		"invalid count": {
+			env:         map[string]string{envListenFDs: "x", envListenPID: strconv.Itoa(pid)},
+			expectedErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			newFile, _ := fakeFDs(t, 2)
+
+			got, parentPID, err := inheritListeners(func(k string) string { return tc.env[k] }, pid, ppid, newFile)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			names := []string{}
+			for _, l := range got {
+				names = append(names, l.name)
+				_ = l.ln.Close()
+			}
+			if len(tc.expectedNames) == 0 {
+				assert.Empty(t, names)
+			} else {
+				assert.Equal(t, tc.expectedNames, names)
+			}
+			assert.Equal(t, tc.expectedParentPID, parentPID)
+		})
+	}
+}
+
+func TestListenersListen(t *testing.T) {
+	t.Parallel()
+
+	newFile, addrs := fakeFDs(t, 2)
+	env := map[string]string{envListenFDs: "2", envListenFDNames: "public:unknown", envListenPID: "1"}
+	inherited, _, err := inheritListeners(func(k string) string { return env[k] }, 1, 0, newFile)
+	require.NoError(t, err)
+
+	l := &Listeners{inherited: inherited}
+	defer l.Close()
+
+	// by name
+	ln, err := l.Listen("public", Address{Network: "tcp", Addr: "0.0.0.0:1"})
+	require.NoError(t, err)
+	assert.Equal(t, addrs[0], ln.Addr().String())
+	defer ln.Close()
+
+	// by address
+	ln2, err := l.Listen("admin", Address{Network: "tcp", Addr: addrs[1]})
+	require.NoError(t, err)
+	assert.Equal(t, addrs[1], ln2.Addr().String())
+	defer ln2.Close()
+
+	// new listener
+	ln3, err := l.Listen("metrics", NewAddress("127.0.0.1", 0, SocketConfig{}))
+	require.NoError(t, err)
+	assert.NotContains(t, addrs, ln3.Addr().String())
+	defer ln3.Close()
+
+	assert.Len(t, l.active, 3)
+	assert.Empty(t, l.inherited)
+	require.NoError(t, l.Ready())
+}
+
+func TestListenersListenPacket(t *testing.T) {
+	t.Parallel()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f, err := pc.(*net.UDPConn).File()
+	require.NoError(t, err)
+	addr := pc.LocalAddr().String()
+	require.NoError(t, pc.Close())
+
+	newFile := func(uintptr, string) *os.File { return f }
+	env := map[string]string{envListenFDs: "1", envListenFDNames: ListenerHTTP3, envListenPID: "1"}
+	inherited, _, err := inheritListeners(func(k string) string { return env[k] }, 1, 0, newFile)
+	require.NoError(t, err)
+	require.Len(t, inherited, 1)
+
+	l := &Listeners{inherited: inherited}
+	defer l.Close()
+
+	// a packet socket is never taken as a stream listener.
+	ln, err := l.Listen(ListenerHTTP3, NewAddress("127.0.0.1", 0, SocketConfig{}))
+	require.NoError(t, err)
+	defer ln.Close()
+	assert.Len(t, l.inherited, 1)
+
+	got, err := l.ListenPacket(ListenerHTTP3, "127.0.0.1:0")
+	require.NoError(t, err)
+	defer got.Close()
+	assert.Equal(t, addr, got.LocalAddr().String())
+	assert.Empty(t, l.inherited)
+
+	// handed off on restart.
+	hf, err := l.active[1].file()
+	require.NoError(t, err)
+	_ = hf.Close()
+}
+
+// chanWriter sends every write (a log record) to the channel.
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestRestartInProgress(t *testing.T) {
+	if len(restartSignals) == 0 {
+		t.Skip("graceful restart is not supported")
+	}
+
+	// a child process is taking over.
+	l := &Listeners{handoff: true}
+
+	_, err := l.Restart(t.Context())
+	require.ErrorIs(t, err, errRestartInProgress)
+
+	// further signals are ignored, no other child is started.
+	records := make(chanWriter, 10)
+	ctx := zlog.SetInContext(t.Context(), slog.New(slog.NewTextHandler(records, nil)))
+	require.NoError(t, l.RestartOnSignal(ctx))
+
+	p, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, p.Signal(restartSignals[0]))
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case record := <-records:
+			if strings.Contains(record, "signal ignored") {
+				assert.True(t, l.handoff)
+				return
+			}
+			assert.NotContains(t, record, "new process started")
+		case <-timeout:
+			t.Fatal("timeout waiting for the restart signal")
+		}
+	}
+}
+
+func TestRestartFailedHandoff(t *testing.T) {
+	if len(restartSignals) == 0 {
+		t.Skip("graceful restart is not supported")
+	}
+
+	// the child is the test binary, that runs no test and exits without taking over.
+	args, stdout, stderr := os.Args, os.Stdout, os.Stderr
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	os.Args, os.Stdout, os.Stderr = []string{args[0], "-test.run=^$"}, devNull, devNull
+	t.Cleanup(func() {
+		os.Args, os.Stdout, os.Stderr = args, stdout, stderr
+		_ = devNull.Close()
+	})
+
+	l := &Listeners{}
+	socket := filepath.Join(socketDir(t), "restart.sock")
+	ln, err := l.Listen(ListenerPublic, Address{Network: "unix", Addr: socket})
+	require.NoError(t, err)
+
+	_, err = l.Restart(t.Context())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return !l.handoff
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// this process keeps serving, and removes its socket file on shutdown.
+	require.NoError(t, ln.Close())
+	assert.NoFileExists(t, socket)
+}
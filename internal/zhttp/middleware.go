@@ -0,0 +1,127 @@
+package zhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+)
+
+// errPanic is the cause of the 500 responses of recovered panics.
+var errPanic = errors.New("panic")
+
+// Recoverer recovers from panics, logs them along with the stack trace and responds with a 500 problem
+// (unless the response was already started). It replaces chi's middleware.Recoverer, which responds with a plain text body.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			if rvr == http.ErrAbortHandler { //nolint:errorlint,err113 // the sentinel panic value of net/http.
+				panic(rvr)
+			}
+
+			ctx := r.Context()
+			zlog.GetFromContext(ctx).ErrorContext(ctx, "panic recovered", slog.Any("panic", rvr), slog.String("stack", string(debug.Stack())))
+
+			if r.Header.Get("Connection") == "Upgrade" || ww.Status() != 0 {
+				return
+			}
+			RespondError(ctx, ww, fmt.Errorf("%w: %v", errPanic, rvr))
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// Timeout cancels the request context after d and, if the handler has not responded by then, responds with a 504 problem.
+// Handlers should honor the context cancellation. It replaces chi's middleware.Timeout, which responds with an empty body.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) && ww.Status() == 0 {
+				RespondError(ctx, ww, ctx.Err())
+			}
+		})
+	}
+}
+
+// RealIP sets the RemoteAddr of the requests of a trusted proxy to the client ip of their X-Forwarded-For header (the
+// right-most address that is not a trusted proxy) or, without it, of their X-Real-IP header. The headers of any other
+// peer are ignored, as clients can set them freely. It replaces chi's middleware.RealIP, which trusts them from any peer.
+func RealIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(trusted) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := forwardedIP(r, trusted); ip.IsValid() {
+				r.RemoteAddr = ip.String()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedIP returns the client ip that a trusted proxy forwarded r for; invalid if r does not come from one,
+// or carries no valid address.
+func forwardedIP(r *http.Request, trusted []netip.Prefix) netip.Addr {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil || !isTrustedProxy(peer.Addr().Unmap(), trusted) {
+		return netip.Addr{}
+	}
+
+	// every proxy appends the address of its peer, so the addresses left of the first untrusted one may be forged.
+	if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+		hops := strings.Split(strings.Join(values, ","), ",")
+		var ip netip.Addr
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			ip = hop.Unmap()
+			if !isTrustedProxy(ip, trusted) {
+				break
+			}
+		}
+
+		return ip
+	}
+
+	ip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	return ip.Unmap()
+}
+
+func isTrustedProxy(ip netip.Addr, trusted []netip.Prefix) bool {
+	for _, p := range trusted {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
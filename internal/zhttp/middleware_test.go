@@ -0,0 +1,118 @@
+package zhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("panic", func(t *testing.T) {
+		t.Parallel()
+
+		resp := httptest.NewRecorder()
+		Recoverer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic("boom")
+		})).ServeHTTP(resp, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, resp.Code)
+		assert.Equal(t, ProblemContentType, resp.Header().Get("Content-Type"))
+		assert.NotContains(t, resp.Body.String(), "boom")
+	})
+
+	t.Run("panic after the response started", func(t *testing.T) {
+		t.Parallel()
+
+		resp := httptest.NewRecorder()
+		Recoverer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			panic("boom")
+		})).ServeHTTP(resp, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusAccepted, resp.Code)
+		assert.Empty(t, resp.Body.String())
+	})
+
+	t.Run("abort handler", func(t *testing.T) {
+		t.Parallel()
+
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			Recoverer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				panic(http.ErrAbortHandler)
+			})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil))
+		})
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+
+	handler := Timeout(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("slow") {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/?slow", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, resp.Code)
+	assert.Equal(t, ProblemContentType, resp.Header().Get("Content-Type"))
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+}
+
+func TestRealIP(t *testing.T) {
+	t.Parallel()
+
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::/32")}
+
+	tests := map[string]struct {
+		trusted    []netip.Prefix
+		remoteAddr string
+		headers    map[string][]string
+		expected   string
+	}{
+		"no trusted proxies":   {remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1"}}, expected: "10.0.0.1:1234"},
+		"untrusted peer":       {trusted: trusted, remoteAddr: "192.0.2.1:1234", headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1"}, "X-Real-Ip": {"1.1.1.1"}}, expected: "192.0.2.1:1234"},
+		"forwarded":            {trusted: trusted, remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1"}}, expected: "1.1.1.1"},
+		"forged hops":          {trusted: trusted, remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"X-Forwarded-For": {"3.3.3.3, 2.2.2.2", "1.1.1.1, 10.0.0.2"}}, expected: "1.1.1.1"},
+		"only proxies":         {trusted: trusted, remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, expected: "10.0.0.3"},
+		"malformed hop":        {trusted: trusted, remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1, x, 10.0.0.2"}}, expected: "10.0.0.2"},
+		"malformed":            {trusted: trusted, remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"X-Forwarded-For": {"x"}, "X-Real-Ip": {"1.1.1.1"}}, expected: "10.0.0.1:1234"},
+		"real ip":              {trusted: trusted, remoteAddr: "10.0.0.1:1234", headers: map[string][]string{"X-Real-Ip": {"1.1.1.1"}}, expected: "1.1.1.1"},
+		"ipv6":                 {trusted: trusted, remoteAddr: "[2001:db8::1]:1234", headers: map[string][]string{"X-Forwarded-For": {"2001:db9::1"}}, expected: "2001:db9::1"},
+		"ipv4 mapped peer":     {trusted: trusted, remoteAddr: "[::ffff:10.0.0.1]:1234", headers: map[string][]string{"X-Real-Ip": {"1.1.1.1"}}, expected: "1.1.1.1"},
+		"no forwarded headers": {trusted: trusted, remoteAddr: "10.0.0.1:1234", expected: "10.0.0.1:1234"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for k, values := range tc.headers {
+				for _, v := range values {
+					req.Header.Add(k, v)
+				}
+			}
+
+			var got string
+			RealIP(tc.trusted)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
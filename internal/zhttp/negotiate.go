@@ -0,0 +1,193 @@
+package zhttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxDecodeBytes is the request body size limit of Decode.
+const DefaultMaxDecodeBytes = 1 << 20
+
+// mediaRange is a single entry of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses the media ranges of an Accept header. Invalid entries are skipped.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for part := range strings.SplitSeq(header, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		typ, subtype, found := strings.Cut(mt, "/")
+		if !found {
+			continue
+		}
+
+		q := 1.0
+		if qs, found := params["q"]; found {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q})
+	}
+
+	return ranges
+}
+
+// quality returns the quality of mediaType according to the most specific matching range (RFC 9110 12.5.1).
+func quality(ranges []mediaRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+
+	return q
+}
+
+// Negotiate returns the registered codec that is preferred by the Accept header of r and supports v.
+// When Accept is missing, or none of its entries parses, the first registered codec (JSON) is used. It returns nil
+// when nothing is acceptable.
+func Negotiate(r *http.Request, v any) Codec {
+	candidates := slices.DeleteFunc(registeredCodecs(), func(c Codec) bool {
+		vc, is := c.(ValueCodec)
+		return is && !vc.Supports(v)
+	})
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	ranges := parseAccept(strings.Join(r.Header.Values("Accept"), ","))
+	if len(ranges) == 0 {
+		return candidates[0]
+	}
+
+	var (
+		best  Codec
+		bestQ float64
+	)
+	for _, c := range candidates {
+		if q := quality(ranges, c.MediaType()); q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+
+	return best
+}
+
+// Respond renders body with the codec negotiated on the Accept header (see Negotiate), or responds with
+// a 406 problem when no registered codec is acceptable. Like RespondJSON, it encodes directly over w unless
+// the Buffered or WithETag options are given.
+func Respond(ctx context.Context, w http.ResponseWriter, r *http.Request, statusCode int, body any, opts ...RespondOption) {
+	w.Header().Add(`Vary`, `Accept`)
+
+	c := Negotiate(r, body)
+	if c == nil {
+		RespondError(ctx, w, &APIError{Status: http.StatusNotAcceptable, Detail: "Supported media types: " + strings.Join(mediaTypes(body), ", ") + "."})
+		return
+	}
+
+	contentType := c.MediaType()
+	if _, isJSON := c.(JSONCodec); isJSON {
+		contentType += "; charset=utf-8"
+	}
+	respond(ctx, w, statusCode, c, contentType, body, opts)
+}
+
+// Decode decodes the body of r into v with the codec of its Content-Type, reading up to DefaultMaxDecodeBytes.
+// See DecodeLimit.
+func Decode(r *http.Request, v any) error {
+	return DecodeLimit(r, v, DefaultMaxDecodeBytes)
+}
+
+// DecodeLimit decodes the body of r into v with the codec of its Content-Type, reading up to limit bytes.
+// Structured syntax suffixes are honored (e.g. application/merge-patch+json uses the json codec).
+// The returned errors are *APIError: 415 for unsupported media types, 413 for larger bodies and 400 for malformed ones,
+// so they can be passed to RespondError as is.
+func DecodeLimit(r *http.Request, v any, limit int64) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return &APIError{Status: http.StatusUnsupportedMediaType, Detail: "A valid Content-Type is required. Supported media types: " + strings.Join(mediaTypes(v), ", ") + ".", Err: err}
+	}
+
+	c := codecFor(mediaType, v)
+	if c == nil {
+		return &APIError{Status: http.StatusUnsupportedMediaType, Detail: "Supported media types: " + strings.Join(mediaTypes(v), ", ") + "."}
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return &APIError{Status: http.StatusBadRequest, Detail: "The request body is empty."}
+	}
+
+	if err := c.Decode(http.MaxBytesReader(nil, r.Body, limit), v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			return &APIError{Status: http.StatusRequestEntityTooLarge, Detail: "The request body exceeds " + strconv.FormatInt(limit, 10) + " bytes.", Err: err}
+		case errors.Is(err, io.EOF):
+			return &APIError{Status: http.StatusBadRequest, Detail: "The request body is empty.", Err: err}
+		default:
+			return &APIError{Status: http.StatusBadRequest, Detail: "The request body is malformed.", Err: err}
+		}
+	}
+
+	return nil
+}
+
+// codecFor returns the codec of mediaType that supports v.
+func codecFor(mediaType string, v any) Codec {
+	_, suffix, hasSuffix := strings.Cut(mediaType, "+")
+
+	var bySuffix Codec
+	for _, c := range registeredCodecs() {
+		if vc, is := c.(ValueCodec); is && !vc.Supports(v) {
+			continue
+		}
+
+		if c.MediaType() == mediaType {
+			return c
+		}
+		if hasSuffix && bySuffix == nil && strings.HasSuffix(c.MediaType(), "/"+suffix) {
+			bySuffix = c
+		}
+	}
+
+	return bySuffix
+}
+
+func mediaTypes(v any) []string {
+	var out []string
+	for _, c := range registeredCodecs() {
+		if vc, is := c.(ValueCodec); is && !vc.Supports(v) {
+			continue
+		}
+		out = append(out, c.MediaType())
+	}
+
+	return out
+}
@@ -0,0 +1,139 @@
+package zhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		accept   []string
+		body     any
+		expected string // empty means not acceptable.
+	}{
+		"no accept":               {body: codecTestBody{}, expected: "application/json"},
+		"any":                     {accept: []string{"*/*"}, body: codecTestBody{}, expected: "application/json"},
+		"exact":                   {accept: []string{"application/yaml"}, body: codecTestBody{}, expected: "application/yaml"},
+		"quality":                 {accept: []string{"application/json;q=0.5, application/cbor"}, body: codecTestBody{}, expected: "application/cbor"},
+		"multiple headers":        {accept: []string{"text/html", "application/msgpack;q=0.9"}, body: codecTestBody{}, expected: "application/msgpack"},
+		"specific range wins":     {accept: []string{"application/*;q=0.1, application/json;q=0, */*;q=0.5"}, body: codecTestBody{}, expected: "application/yaml"},
+		"excluded":                {accept: []string{"application/json;q=0"}, body: codecTestBody{}, expected: ""},
+		"not acceptable":          {accept: []string{"text/html"}, body: codecTestBody{}, expected: ""},
+		"protobuf for messages":   {accept: []string{"application/protobuf, application/json;q=0.1"}, body: wrapperspb.String("x"), expected: "application/protobuf"},
+		"no protobuf for structs": {accept: []string{"application/protobuf"}, body: codecTestBody{}, expected: ""},
+		"invalid entries skipped": {accept: []string{"application/json;q=x, ???, application/cbor;q=0.2"}, body: codecTestBody{}, expected: "application/cbor"},
+		"only invalid entries":    {accept: []string{"???, application/json;q=x"}, body: codecTestBody{}, expected: "application/json"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			for _, a := range tc.accept {
+				req.Header.Add("Accept", a)
+			}
+
+			c := Negotiate(req, tc.body)
+			if tc.expected == "" {
+				assert.Nil(t, c)
+				return
+			}
+			require.NotNil(t, c)
+			assert.Equal(t, tc.expected, c.MediaType())
+		})
+	}
+}
+
+func TestRespond(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/yaml")
+	resp := httptest.NewRecorder()
+	Respond(t.Context(), resp, req, http.StatusCreated, map[string]string{"a": "b"})
+
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	assert.Equal(t, "application/yaml", resp.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", resp.Header().Get("Vary"))
+	assert.Equal(t, "a: b\n", resp.Body.String())
+
+	req.Header.Set("Accept", "text/html")
+	resp = httptest.NewRecorder()
+	Respond(t.Context(), resp, req, http.StatusOK, map[string]string{"a": "b"})
+
+	assert.Equal(t, http.StatusNotAcceptable, resp.Code)
+	assert.Equal(t, ProblemContentType, resp.Header().Get("Content-Type"))
+}
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	jsonBody, err := json.Marshal(codecTestBody{Name: "n"})
+	require.NoError(t, err)
+	cborBody := &bytes.Buffer{}
+	require.NoError(t, CBORCodec{}.Encode(cborBody, codecTestBody{Name: "n"}))
+
+	tests := map[string]struct {
+		contentType    string
+		body           string
+		limit          int64
+		expectedStatus int // 0 means success.
+	}{
+		"json":               {contentType: "application/json; charset=utf-8", body: string(jsonBody)},
+		"suffix":             {contentType: "application/merge-patch+json", body: string(jsonBody)},
+		"cbor":               {contentType: "application/cbor", body: cborBody.String()},
+		"yaml":               {contentType: "application/yaml", body: "name: n\n"},
+		"missing type":       {body: string(jsonBody), expectedStatus: http.StatusUnsupportedMediaType},
+		"unsupported type":   {contentType: "text/plain", body: "n", expectedStatus: http.StatusUnsupportedMediaType},
+		"proto for structs":  {contentType: "application/protobuf", body: "x", expectedStatus: http.StatusUnsupportedMediaType},
+		"malformed":          {contentType: "application/json", body: "{", expectedStatus: http.StatusBadRequest},
+		"trailing data":      {contentType: "application/json", body: string(jsonBody) + "{}", expectedStatus: http.StatusBadRequest},
+		"trailing delimiter": {contentType: "application/json", body: string(jsonBody) + "}", expectedStatus: http.StatusBadRequest},
+		"trailing space":     {contentType: "application/json", body: string(jsonBody) + " \n"},
+		"empty":              {contentType: "application/json", expectedStatus: http.StatusBadRequest},
+		"too large":          {contentType: "application/json", body: string(jsonBody), limit: 4, expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/", strings.NewReader(tc.body))
+			if tc.body == "" {
+				req.Body = http.NoBody
+			}
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			limit := tc.limit
+			if limit == 0 {
+				limit = DefaultMaxDecodeBytes
+			}
+
+			var out codecTestBody
+			err := DecodeLimit(req, &out, limit)
+			if tc.expectedStatus == 0 {
+				require.NoError(t, err)
+				assert.Equal(t, "n", out.Name)
+				return
+			}
+
+			var apiErr *APIError
+			require.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, tc.expectedStatus, apiErr.Status)
+		})
+	}
+}
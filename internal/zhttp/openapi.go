@@ -0,0 +1,429 @@
+package zhttp
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification of the generated documents.
+const OpenAPIVersion = "3.1.0"
+
+// OpenAPIConfig is the config of the OpenAPI document and docs UI of the public router.
+type OpenAPIConfig struct {
+	Enabled bool `koanf:"enabled" default:"true"  desc:"Serves the OpenAPI document of the public routes at /openapi.json."`
+	DocsUI  bool `koanf:"docs_ui" default:"false" desc:"Serves an embedded API docs page, rendered from /openapi.json, at /docs/."`
+}
+
+// OpenAPI is an OpenAPI 3.1 document (https://spec.openapis.org/oas/v3.1.0), limited to what API generates.
+type OpenAPI struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       OpenAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"` // path, then lower case method.
+	Components OpenAPIComponents                `json:"components,omitzero"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenAPIComponents struct {
+	Schemas         map[string]map[string]any `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme documents an authentication method, e.g. {Type: "http", Scheme: "basic"}
+// or {Type: "apiKey", In: "header", Name: "X-API-Key"}.
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      map[string]any `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema map[string]any `json:"schema"`
+}
+
+// OperationOption documents an operation registered through API.
+type OperationOption func(*Operation)
+
+func WithSummary(summary string) OperationOption {
+	return func(o *Operation) { o.Summary = summary }
+}
+
+func WithDescription(description string) OperationOption {
+	return func(o *Operation) { o.Description = description }
+}
+
+func WithTags(tags ...string) OperationOption {
+	return func(o *Operation) { o.Tags = append(o.Tags, tags...) }
+}
+
+func WithOperationID(id string) OperationOption {
+	return func(o *Operation) { o.OperationID = id }
+}
+
+func Deprecated() OperationOption {
+	return func(o *Operation) { o.Deprecated = true }
+}
+
+// WithSecurity documents that the operation accepts any of the given security schemes (see API.AddSecurityScheme),
+// overriding the ones of API.RequireSecurity.
+func WithSecurity(schemes ...string) OperationOption {
+	return func(o *Operation) { o.Security = securityRequirements(schemes, nil) }
+}
+
+// securityRequirements returns the requirements of any of the schemes, each with the required roles and scopes.
+func securityRequirements(schemes, scopes []string) []map[string][]string {
+	out := make([]map[string][]string, 0, len(schemes))
+	for _, s := range schemes {
+		out = append(out, map[string][]string{s: append([]string{}, scopes...)})
+	}
+
+	return out
+}
+
+// API registers routes on a chi.Router and documents them in an OpenAPI document. Typed handlers (see Register)
+// are documented along with their parameters, request body and response; plain handlers (see API.Method) with
+// their path parameters only. Groups share the document of the API they were created from.
+type API struct {
+	router   chi.Router
+	doc      *apiDoc
+	prefix   string
+	security []string
+	scopes   []string // the roles and scopes required by Authorize.
+}
+
+type apiDoc struct {
+	mu         sync.Mutex
+	info       OpenAPIInfo
+	operations []apiOperation
+	schemes    map[string]SecurityScheme
+}
+
+type apiOperation struct {
+	method    string
+	pattern   string
+	req, resp reflect.Type // nil for plain handlers.
+	security  []string
+	scopes    []string
+	opts      []OperationOption
+}
+
+// NewAPI returns an API that registers the routes on router.
+func NewAPI(router chi.Router, info OpenAPIInfo) *API {
+	return &API{
+		router: router,
+		doc:    &apiDoc{info: info, schemes: map[string]SecurityScheme{}},
+	}
+}
+
+// Router returns the chi.Router of a, e.g. to register routes that are not documented.
+func (a *API) Router() chi.Router {
+	return a.router
+}
+
+func (a *API) Use(middlewares ...func(http.Handler) http.Handler) {
+	a.router.Use(middlewares...)
+}
+
+// Group creates a group (see chi.Router.Group) that inherits the middlewares and the security requirements of a.
+func (a *API) Group(fn func(a *API)) {
+	a.router.Group(func(r chi.Router) {
+		fn(&API{router: r, doc: a.doc, prefix: a.prefix, security: a.security, scopes: a.scopes})
+	})
+}
+
+// Route creates a sub router mounted at pattern (see chi.Router.Route).
+func (a *API) Route(pattern string, fn func(a *API)) {
+	a.router.Route(pattern, func(r chi.Router) {
+		fn(&API{router: r, doc: a.doc, prefix: a.prefix + strings.TrimSuffix(pattern, "/"), security: a.security, scopes: a.scopes})
+	})
+}
+
+// AddSecurityScheme documents the security scheme name, to be referenced by RequireSecurity and WithSecurity.
+func (a *API) AddSecurityScheme(name string, s SecurityScheme) {
+	a.doc.mu.Lock()
+	defer a.doc.mu.Unlock()
+
+	a.doc.schemes[name] = s
+}
+
+// RequireSecurity documents that the operations registered afterwards on a (and on its groups) accept any of the
+// given security schemes. It only documents them; the authentication middlewares are installed with Use.
+func (a *API) RequireSecurity(schemes ...string) {
+	a.security = slices.Clone(schemes)
+}
+
+// Authenticate installs the middleware of auths that accepts any of the named authenticators (see
+// Authenticators.Require) and documents them as the security requirements of the operations registered afterwards.
+func (a *API) Authenticate(auths Authenticators, names ...string) {
+	a.Use(auths.Require(names...))
+	a.RequireSecurity(names...)
+}
+
+// Authorize installs the middleware of policy that requires req of the principal (see Policy.Require) and documents
+// the required roles and scopes in the security requirements of the operations registered afterwards.
+func (a *API) Authorize(policy *Policy, req Requirement) {
+	a.Use(policy.Require(req))
+	a.scopes = union(union(a.scopes, req.Roles), req.Scopes)
+}
+
+// Method registers a plain handler for method and pattern.
+func (a *API) Method(method, pattern string, h http.Handler, opts ...OperationOption) {
+	a.router.Method(method, pattern, h)
+	a.add(method, pattern, nil, nil, opts)
+}
+
+func (a *API) add(method, pattern string, req, resp reflect.Type, opts []OperationOption) {
+	a.doc.mu.Lock()
+	defer a.doc.mu.Unlock()
+
+	a.doc.operations = append(a.doc.operations, apiOperation{
+		method:   method,
+		pattern:  a.prefix + pattern,
+		req:      req,
+		resp:     resp,
+		security: a.security,
+		scopes:   a.scopes,
+		opts:     opts,
+	})
+}
+
+// Register registers the typed handler fn (see Handle) for method and pattern, and documents it.
+func Register[Req, Resp any](a *API, method, pattern string, fn func(context.Context, Req) (Resp, error), opts ...OperationOption) {
+	a.router.Method(method, pattern, Handle(fn))
+	a.add(method, pattern, reflect.TypeFor[Req](), reflect.TypeFor[Resp](), opts)
+}
+
+func Get[Req, Resp any](a *API, pattern string, fn func(context.Context, Req) (Resp, error), opts ...OperationOption) {
+	Register(a, http.MethodGet, pattern, fn, opts...)
+}
+
+func Post[Req, Resp any](a *API, pattern string, fn func(context.Context, Req) (Resp, error), opts ...OperationOption) {
+	Register(a, http.MethodPost, pattern, fn, opts...)
+}
+
+func Put[Req, Resp any](a *API, pattern string, fn func(context.Context, Req) (Resp, error), opts ...OperationOption) {
+	Register(a, http.MethodPut, pattern, fn, opts...)
+}
+
+func Patch[Req, Resp any](a *API, pattern string, fn func(context.Context, Req) (Resp, error), opts ...OperationOption) {
+	Register(a, http.MethodPatch, pattern, fn, opts...)
+}
+
+func Delete[Req, Resp any](a *API, pattern string, fn func(context.Context, Req) (Resp, error), opts ...OperationOption) {
+	Register(a, http.MethodDelete, pattern, fn, opts...)
+}
+
+// OpenAPI generates the OpenAPI document of the registered operations.
+func (a *API) OpenAPI() *OpenAPI {
+	a.doc.mu.Lock()
+	defer a.doc.mu.Unlock()
+
+	g := newSchemaGenerator()
+	doc := &OpenAPI{
+		OpenAPI: OpenAPIVersion,
+		Info:    a.doc.info,
+		Paths:   map[string]map[string]*Operation{},
+	}
+
+	problemSchema := g.schema(reflect.TypeFor[APIError]())
+
+	for _, ao := range a.doc.operations {
+		p, pathParams := openAPIPath(ao.pattern)
+		op := &Operation{Security: securityRequirements(ao.security, ao.scopes)}
+
+		if ao.req != nil {
+			op.Parameters, op.RequestBody = requestSchema(g, ao.req)
+		}
+		for _, name := range pathParams {
+			if !slices.ContainsFunc(op.Parameters, func(p Parameter) bool { return p.In == tagPath && p.Name == name }) {
+				op.Parameters = append(op.Parameters, Parameter{Name: name, In: tagPath, Required: true, Schema: map[string]any{"type": "string"}})
+			}
+		}
+
+		op.Responses = map[string]Response{
+			"default": {Description: "Problem details (RFC 9457).", Content: map[string]MediaType{ProblemContentType: {Schema: problemSchema}}},
+		}
+		if ao.resp != nil {
+			status, r := responseSchema(g, ao.resp)
+			op.Responses[status] = r
+		} else {
+			op.Responses["200"] = Response{Description: http.StatusText(http.StatusOK)}
+		}
+
+		for _, opt := range ao.opts {
+			opt(op)
+		}
+
+		if doc.Paths[p] == nil {
+			doc.Paths[p] = map[string]*Operation{}
+		}
+		doc.Paths[p][strings.ToLower(ao.method)] = op
+	}
+
+	doc.Components.Schemas = g.components
+	if len(a.doc.schemes) > 0 {
+		doc.Components.SecuritySchemes = a.doc.schemes
+	}
+
+	return doc
+}
+
+// chiParamRegexp matches the (optionally regexp constrained) url params of chi patterns, e.g. {id} or {id:[0-9]+}.
+var chiParamRegexp = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// openAPIPath converts a chi pattern to an OpenAPI path and returns the names of its parameters.
+func openAPIPath(pattern string) (string, []string) {
+	var names []string
+	p := chiParamRegexp.ReplaceAllStringFunc(pattern, func(m string) string {
+		name := chiParamRegexp.FindStringSubmatch(m)[1]
+		names = append(names, name)
+		return "{" + name + "}"
+	})
+
+	return p, names
+}
+
+// requestSchema documents the parameters and the body of a request type bound by Bind.
+func requestSchema(g *schemaGenerator, t reflect.Type) ([]Parameter, *RequestBody) {
+	if t.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	var (
+		params []Parameter
+		body   *RequestBody
+	)
+	for i := range t.NumField() {
+		sf := t.Field(i)
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			ps, b := requestSchema(g, sf.Type)
+			params = append(params, ps...)
+			if b != nil {
+				body = b
+			}
+			continue
+		}
+
+		if _, isBody := sf.Tag.Lookup(tagBody); isBody {
+			bt := sf.Type
+			if bt.Kind() == reflect.Pointer {
+				bt = bt.Elem()
+			}
+			schema := g.schema(bt)
+			body = &RequestBody{Required: sf.Type.Kind() != reflect.Pointer, Content: map[string]MediaType{}}
+			for _, mt := range mediaTypes(reflect.New(bt).Interface()) {
+				body.Content[mt] = MediaType{Schema: schema}
+			}
+			continue
+		}
+
+		for _, in := range []string{tagPath, tagQuery, tagHeader} {
+			name := sf.Tag.Get(in)
+			if name == "" {
+				continue
+			}
+			schema, required := g.paramSchema(sf)
+			params = append(params, Parameter{
+				Name:        name,
+				In:          in,
+				Description: sf.Tag.Get(descTag),
+				Required:    required || in == tagPath,
+				Schema:      schema,
+			})
+		}
+	}
+
+	return params, body
+}
+
+// responseSchema documents the success response of a typed handler (see Handle).
+func responseSchema(g *schemaGenerator, t reflect.Type) (string, Response) {
+	status := http.StatusOK
+	if sc, is := reflect.New(t).Elem().Interface().(StatusCoder); is {
+		status = sc.StatusCode()
+	}
+
+	r := Response{Description: http.StatusText(status)}
+	if status != http.StatusNoContent {
+		schema := g.schema(t)
+		r.Content = map[string]MediaType{}
+		for _, mt := range mediaTypes(reflect.New(t).Interface()) {
+			r.Content[mt] = MediaType{Schema: schema}
+		}
+	}
+
+	return strconv.Itoa(status), r
+}
+
+// OpenAPIHandler serves the OpenAPI document of a as json.
+func OpenAPIHandler(a *API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		RespondJSON(r.Context(), w, http.StatusOK, a.OpenAPI(), WithETag(r))
+	}
+}
+
+//go:embed docsui
+var docsUI embed.FS
+
+// DocsHandler serves the embedded API docs page, which renders the OpenAPI document at /openapi.json,
+// under prefix (e.g. router.Mount("/docs", DocsHandler("/docs"))).
+func DocsHandler(prefix string) http.Handler {
+	sub, _ := fs.Sub(docsUI, "docsui") // the directory is embedded.
+	files := http.StripPrefix(prefix, http.FileServerFS(sub))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == prefix {
+			http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,158 @@
+package zhttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type openAPITestItem struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name" validate:"required"`
+}
+
+type openAPITestCreated struct {
+	openAPITestItem
+}
+
+func (openAPITestCreated) StatusCode() int { return http.StatusCreated }
+
+type openAPITestGetRequest struct {
+	ID    int64  `path:"id"`
+	Limit int    `query:"limit"   default:"10" validate:"max=100" desc:"Page size."`
+	Trace string `header:"X-Trace" validate:"required"`
+}
+
+type openAPITestCreateRequest struct {
+	Body openAPITestItem `body:""`
+}
+
+func newOpenAPITestAPI() (*chi.Mux, *API) {
+	router := chi.NewRouter()
+	api := NewAPI(router, OpenAPIInfo{Title: "test", Version: "1.0.0"})
+	api.AddSecurityScheme("key", SecurityScheme{Type: "apiKey", In: "header", Name: "X-API-Key"})
+
+	api.Route("/items", func(api *API) {
+		Get(api, "/{id:[0-9]+}", func(_ context.Context, req openAPITestGetRequest) (openAPITestItem, error) {
+			return openAPITestItem{ID: req.ID}, nil
+		}, WithSummary("Get an item."), WithOperationID("getItem"))
+
+		api.Group(func(api *API) {
+			api.RequireSecurity("key")
+			Post(api, "/", func(_ context.Context, req openAPITestCreateRequest) (openAPITestCreated, error) {
+				return openAPITestCreated{req.Body}, nil
+			}, WithTags("items"))
+			Delete(api, "/{id}", func(_ context.Context, _ struct{}) (NoContent, error) {
+				return NoContent{}, nil
+			}, Deprecated())
+		})
+	})
+	api.Method(http.MethodGet, "/files/{name}", http.NotFoundHandler(), WithSecurity())
+
+	router.Get("/openapi.json", OpenAPIHandler(api))
+	router.Mount("/docs", DocsHandler("/docs"))
+
+	return router, api
+}
+
+func TestAPIOpenAPI(t *testing.T) {
+	t.Parallel()
+
+	_, api := newOpenAPITestAPI()
+	doc := api.OpenAPI()
+
+	assert.Equal(t, OpenAPIVersion, doc.OpenAPI)
+	assert.Equal(t, OpenAPIInfo{Title: "test", Version: "1.0.0"}, doc.Info)
+	assert.ElementsMatch(t, []string{"/items/{id}", "/items/", "/files/{name}"}, keys(doc.Paths))
+
+	get := doc.Paths["/items/{id}"]["get"]
+	require.NotNil(t, get)
+	assert.Equal(t, "getItem", get.OperationID)
+	assert.Equal(t, "Get an item.", get.Summary)
+	assert.Empty(t, get.Security)
+	assert.Equal(t, []Parameter{
+		{Name: "id", In: "path", Required: true, Schema: map[string]any{"type": "integer", "format": "int64"}},
+		{Name: "limit", In: "query", Description: "Page size.", Schema: map[string]any{"type": "integer", "format": "int64", "default": 10, "maximum": 100.0}},
+		{Name: "X-Trace", In: "header", Required: true, Schema: map[string]any{"type": "string", "minLength": 1}},
+	}, get.Parameters)
+	assert.Nil(t, get.RequestBody)
+	assert.Equal(t, map[string]any{"$ref": "#/components/schemas/openAPITestItem"}, get.Responses["200"].Content["application/json"].Schema)
+	assert.Contains(t, get.Responses["default"].Content, ProblemContentType)
+
+	post := doc.Paths["/items/"]["post"]
+	require.NotNil(t, post)
+	assert.Equal(t, []map[string][]string{{"key": {}}}, post.Security)
+	assert.Equal(t, []string{"items"}, post.Tags)
+	require.NotNil(t, post.RequestBody)
+	assert.True(t, post.RequestBody.Required)
+	assert.Equal(t, map[string]any{"$ref": "#/components/schemas/openAPITestItem"}, post.RequestBody.Content["application/json"].Schema)
+	assert.Contains(t, post.RequestBody.Content, "application/yaml")
+	assert.Contains(t, post.Responses, "201")
+
+	del := doc.Paths["/items/{id}"]["delete"]
+	require.NotNil(t, del)
+	assert.True(t, del.Deprecated)
+	assert.Equal(t, Response{Description: "No Content"}, del.Responses["204"])
+	assert.Equal(t, []Parameter{{Name: "id", In: "path", Required: true, Schema: map[string]any{"type": "string"}}}, del.Parameters)
+
+	files := doc.Paths["/files/{name}"]["get"]
+	require.NotNil(t, files)
+	assert.Empty(t, files.Security)
+	assert.Equal(t, "OK", files.Responses["200"].Description)
+
+	assert.Contains(t, doc.Components.Schemas, "openAPITestItem")
+	assert.Contains(t, doc.Components.Schemas, "openAPITestCreated")
+	assert.Contains(t, doc.Components.Schemas, "APIError")
+	assert.Equal(t, SecurityScheme{Type: "apiKey", In: "header", Name: "X-API-Key"}, doc.Components.SecuritySchemes["key"])
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	t.Parallel()
+
+	router, _ := newOpenAPITestAPI()
+
+	// the registered routes serve.
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/items/7", nil)
+	req.Header.Set("X-Trace", "abc")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"id":7,"name":""}`, resp.Body.String())
+
+	req = httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/openapi.json", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.NotEmpty(t, resp.Header().Get("ETag"))
+	doc := map[string]any{}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &doc))
+	assert.Equal(t, OpenAPIVersion, doc["openapi"])
+
+	req = httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/docs", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusMovedPermanently, resp.Code)
+	assert.Equal(t, "/docs/", resp.Header().Get("Location"))
+
+	for _, p := range []string{"/docs/", "/docs/docs.js", "/docs/docs.css"} {
+		req = httptest.NewRequestWithContext(t.Context(), http.MethodGet, p, nil)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code, p)
+	}
+}
+
+func keys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+
+	return out
+}
@@ -0,0 +1,383 @@
+package zhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+)
+
+// Rate limiting algorithms.
+const (
+	RateLimitTokenBucket   = "token_bucket"
+	RateLimitSlidingWindow = "sliding_window"
+)
+
+// What the rate limits are kept per.
+const (
+	RateLimitKeyIP        = "ip"
+	RateLimitKeyPrincipal = "principal"
+	RateLimitKeyRoute     = "route"
+)
+
+type RateLimitConfig struct {
+	Enabled   bool          `koanf:"enabled"   default:"false"                                                      desc:"Limits the request rate per key; requests over the limit get 429."`
+	Algorithm string        `koanf:"algorithm" default:"token_bucket" validate:"oneof=token_bucket sliding_window" desc:"token_bucket allows bursts up to burst requests, refilled at requests per window; sliding_window allows requests per rolling window."`
+	Key       string        `koanf:"key"       default:"ip"           validate:"oneof=ip principal route"          desc:"What the limit is kept per: the client ip (the socket peer, or the address forwarded by one of the trusted_proxies), the authenticated principal (every request is limited per ip too, ahead of the authentication, so that failed attempts count) or the route pattern."`
+	Requests  int           `koanf:"requests"  default:"100"          validate:"min=1"                              desc:"The requests allowed per window."`
+	Window    time.Duration `koanf:"window"    default:"1m"           validate:"gt=0"                               desc:"The window of requests."`
+	Burst     int           `koanf:"burst"     default:"0"            validate:"min=0"                              desc:"The requests allowed at once by token_bucket (the bucket capacity). 0 means requests."`
+}
+
+// Limit returns the limit of c.
+func (c RateLimitConfig) Limit() RateLimit {
+	return RateLimit{Algorithm: c.Algorithm, Requests: c.Requests, Window: c.Window, Burst: c.Burst}
+}
+
+// RateLimit is a limit of requests per window.
+type RateLimit struct {
+	Algorithm string
+	Requests  int
+	Window    time.Duration
+	Burst     int // the capacity of the token bucket; 0 means Requests.
+}
+
+func (l RateLimit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+
+	return l.Requests
+}
+
+// RateLimitResult is the outcome of a request against a limit.
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int           // the requests still allowed right away.
+	Reset      time.Duration // the time until the whole quota is available again.
+	RetryAfter time.Duration // the time until the next request is allowed, when denied.
+}
+
+// RateLimitStore keeps the state of the limits per key. MemoryRateLimitStore keeps it in process; a store shared
+// by every instance (e.g. RedisRateLimitStore) makes the limits global.
+type RateLimitStore interface {
+	// Take counts a request of key against l at now, if it is allowed.
+	Take(ctx context.Context, key string, l RateLimit, now time.Time) (RateLimitResult, error)
+}
+
+// RateLimiter limits the rate of requests per key (see RateLimitConfig.Key).
+type RateLimiter struct {
+	store RateLimitStore
+	limit RateLimit
+	key   string
+	now   func() time.Time
+}
+
+func NewRateLimiter(c RateLimitConfig, store RateLimitStore) *RateLimiter {
+	return &RateLimiter{store: store, limit: c.Limit(), key: c.Key, now: time.Now}
+}
+
+// Middleware counts every request against the limit of its key and sends the RateLimit-Policy, RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers (IETF httpapi-ratelimit-headers). Requests over the limit get a
+// 429 problem with Retry-After. If the store fails, the request is let through (and the error is logged).
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	policy := fmt.Sprintf("%d;w=%d", rl.limit.Requests, int(math.Ceil(rl.limit.Window.Seconds())))
+	if rl.limit.Algorithm == RateLimitTokenBucket {
+		policy += ";burst=" + strconv.Itoa(rl.limit.burst())
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		res, err := rl.store.Take(ctx, rl.requestKey(r), rl.limit, rl.now())
+		if err != nil {
+			zlog.GetFromContext(ctx).WarnContext(ctx, "rate limit store error", zlog.Error(err))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Policy", policy)
+		h.Set("RateLimit-Limit", strconv.Itoa(rl.limit.Requests))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+
+		if !res.Allowed {
+			h.Set("Retry-After", ceilSeconds(max(res.RetryAfter, time.Second)))
+			RespondError(ctx, w, NewAPIError(http.StatusTooManyRequests, "The rate limit is exceeded."))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestKey returns the key of r; the kind of key is a prefix, so keys of different kinds never collide in a shared store.
+func (rl *RateLimiter) requestKey(r *http.Request) string {
+	switch rl.key {
+	case RateLimitKeyPrincipal:
+		if p, found := PrincipalFromContext(r.Context()); found {
+			return "principal:" + p.Authenticator + ":" + p.Subject
+		}
+	case RateLimitKeyRoute:
+		return "route:" + r.Method + " " + routePattern(r)
+	}
+
+	// RemoteAddr is the client ip after RealIP, or ip:port.
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return "ip:" + ip
+}
+
+// routePattern returns the pattern of the route that r matches, even before the routing (in the middlewares of the
+// router); empty when no route matches.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+	if p := rctx.RoutePattern(); p != "" && p != "/*" {
+		return p
+	}
+	if rctx.Routes == nil {
+		return ""
+	}
+
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+
+	return rctx.Routes.Find(chi.NewRouteContext(), r.Method, path)
+}
+
+// ceilSeconds formats d as whole seconds, rounded up.
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(max(d, 0).Seconds())))
+}
+
+const rateLimitShards = 64
+
+// MemoryRateLimitStore is an in process RateLimitStore. The keys are spread over shards, each with its own lock,
+// so that concurrent requests of different keys seldom contend.
+type MemoryRateLimitStore struct {
+	seed   maphash.Seed
+	shards [rateLimitShards]rateLimitShard
+}
+
+type rateLimitShard struct {
+	mu      sync.Mutex
+	entries map[string]*rateLimitEntry
+}
+
+type rateLimitEntry struct {
+	// token bucket.
+	tokens float64
+	last   time.Time
+
+	// sliding window: the counts of the current and the previous window.
+	window     int64
+	curr, prev int
+
+	expires time.Time // when the entry is equivalent to a new one.
+}
+
+// NewMemoryRateLimitStore returns a MemoryRateLimitStore that evicts the idle keys every interval until ctx is done.
+func NewMemoryRateLimitStore(ctx context.Context, interval time.Duration) *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{seed: maphash.MakeSeed()}
+	for i := range s.shards {
+		s.shards[i].entries = map[string]*rateLimitEntry{}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.evict(now)
+			}
+		}
+	}()
+
+	return s
+}
+
+func (s *MemoryRateLimitStore) evict(now time.Time) {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		for k, e := range sh.entries {
+			if now.After(e.expires) {
+				delete(sh.entries, k)
+			}
+		}
+		sh.mu.Unlock()
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, l RateLimit, now time.Time) (RateLimitResult, error) {
+	sh := &s.shards[maphash.String(s.seed, key)%rateLimitShards]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	e, found := sh.entries[key]
+	if !found {
+		e = &rateLimitEntry{tokens: float64(l.burst()), last: now}
+		sh.entries[key] = e
+	}
+
+	switch l.Algorithm {
+	case RateLimitTokenBucket:
+		return e.takeToken(l, now), nil
+	case RateLimitSlidingWindow:
+		e.slide(l.Window, now)
+		res := slidingWindow(l, now, e.prev, e.curr)
+		if res.Allowed {
+			e.curr++
+		}
+		e.expires = now.Add(2 * l.Window)
+		return res, nil
+	default:
+		return RateLimitResult{}, fmt.Errorf("unknown rate limit algorithm %q", l.Algorithm)
+	}
+}
+
+func (e *rateLimitEntry) takeToken(l RateLimit, now time.Time) RateLimitResult {
+	capacity := float64(l.burst())
+	rate := float64(l.Requests) / l.Window.Seconds() // tokens per second.
+
+	e.tokens = min(capacity, e.tokens+now.Sub(e.last).Seconds()*rate)
+	e.last = now
+
+	res := RateLimitResult{Allowed: e.tokens >= 1}
+	if res.Allowed {
+		e.tokens--
+	} else {
+		res.RetryAfter = seconds((1 - e.tokens) / rate)
+	}
+	res.Remaining = int(e.tokens)
+	res.Reset = seconds((capacity - e.tokens) / rate)
+	e.expires = now.Add(res.Reset)
+
+	return res
+}
+
+// slide moves the counts of e to the window of now.
+func (e *rateLimitEntry) slide(window time.Duration, now time.Time) {
+	idx := now.UnixNano() / int64(window)
+	switch {
+	case idx == e.window:
+		return
+	case idx == e.window+1:
+		e.prev = e.curr
+	default:
+		e.prev = 0
+	}
+	e.curr = 0
+	e.window = idx
+}
+
+// slidingWindow decides a request at now given the counts of the previous and the current window. The count of the
+// rolling window is estimated as the count of the current window plus the part of the previous window that it still
+// overlaps, assuming the requests of the previous window were evenly spread.
+func slidingWindow(l RateLimit, now time.Time, prev, curr int) RateLimitResult {
+	elapsed := time.Duration(now.UnixNano() % int64(l.Window))
+	overlap := 1 - float64(elapsed)/float64(l.Window)
+	estimated := float64(prev)*overlap + float64(curr)
+	limit := float64(l.Requests)
+
+	res := RateLimitResult{Allowed: estimated+1 <= limit, Reset: l.Window - elapsed}
+	if res.Allowed {
+		res.Remaining = int(limit - estimated - 1)
+		return res
+	}
+
+	// the previous window keeps sliding out; if that is not enough, wait for the next one.
+	if curr+1 <= l.Requests && prev > 0 {
+		// prev * (1 - t/window) + curr + 1 <= limit
+		t := time.Duration((1 - (limit-float64(curr)-1)/float64(prev)) * float64(l.Window))
+		res.RetryAfter = t - elapsed
+	} else {
+		res.RetryAfter = l.Window - elapsed
+	}
+
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+// RedisClient is the subset of the commands of a Redis compatible server (Redis, Valkey, KeyDB, etc) that
+// RedisRateLimitStore uses. Adapting a client (e.g. go-redis) takes a few lines.
+type RedisClient interface {
+	// IncrBy increments the integer of key by n (INCRBY) and returns the new value.
+	IncrBy(ctx context.Context, key string, n int64) (int64, error)
+	// PExpire sets the time to live of key (PEXPIRE).
+	PExpire(ctx context.Context, key string, ttl time.Duration) error
+	// GetInt returns the integer of key (GET), or 0 if the key does not exist.
+	GetInt(ctx context.Context, key string) (int64, error)
+}
+
+// RedisRateLimitStore keeps the limits in a Redis compatible server, shared by every instance. It implements the
+// sliding window only, with a counter per key and window whose increments are atomic.
+type RedisRateLimitStore struct {
+	client RedisClient
+	prefix string
+}
+
+// NewRedisRateLimitStore returns a RedisRateLimitStore whose keys start with prefix (e.g. goboilerplate:ratelimit:).
+func NewRedisRateLimitStore(client RedisClient, prefix string) *RedisRateLimitStore {
+	return &RedisRateLimitStore{client: client, prefix: prefix}
+}
+
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, l RateLimit, now time.Time) (RateLimitResult, error) {
+	if l.Algorithm != RateLimitSlidingWindow {
+		return RateLimitResult{}, fmt.Errorf("redis rate limit store: %w algorithm %q", errors.ErrUnsupported, l.Algorithm)
+	}
+
+	idx := now.UnixNano() / int64(l.Window)
+	currKey := s.prefix + key + ":" + strconv.FormatInt(idx, 10)
+	prevKey := s.prefix + key + ":" + strconv.FormatInt(idx-1, 10)
+
+	prev, err := s.client.GetInt(ctx, prevKey)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	// counted upfront, so concurrent requests of every instance see each other; a denied request is uncounted.
+	curr, err := s.client.IncrBy(ctx, currKey, 1)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if curr == 1 {
+		// the counter is needed for the next window too, as the previous one.
+		if err := s.client.PExpire(ctx, currKey, 2*l.Window); err != nil {
+			return RateLimitResult{}, err
+		}
+	}
+
+	res := slidingWindow(l, now, int(prev), int(curr-1))
+	if !res.Allowed {
+		if _, err := s.client.IncrBy(ctx, currKey, -1); err != nil {
+			return RateLimitResult{}, err
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,313 @@
+package zhttp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is a local, in process fake of the commands of RedisClient.
+type fakeRedis struct {
+	mu     sync.Mutex
+	values map[string]int64
+	ttls   map[string]time.Duration
+	err    error
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{values: map[string]int64{}, ttls: map[string]time.Duration{}}
+}
+
+func (f *fakeRedis) IncrBy(_ context.Context, key string, n int64) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.values[key] += n
+
+	return f.values[key], nil
+}
+
+func (f *fakeRedis) PExpire(_ context.Context, key string, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.ttls[key] = ttl
+
+	return f.err
+}
+
+func (f *fakeRedis) GetInt(_ context.Context, key string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.values[key], f.err
+}
+
+func TestTokenBucket(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryRateLimitStore(t.Context(), time.Minute)
+	l := RateLimit{Algorithm: RateLimitTokenBucket, Requests: 3, Window: time.Minute, Burst: 2}
+	now := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)
+
+	take := func(at time.Time) RateLimitResult {
+		res, err := store.Take(t.Context(), "k", l, at)
+		require.NoError(t, err)
+		return res
+	}
+
+	assert.Equal(t, RateLimitResult{Allowed: true, Remaining: 1, Reset: 20 * time.Second}, take(now))
+	assert.Equal(t, RateLimitResult{Allowed: true, Remaining: 0, Reset: 40 * time.Second}, take(now))
+	assert.Equal(t, RateLimitResult{Allowed: false, Remaining: 0, Reset: 40 * time.Second, RetryAfter: 20 * time.Second}, take(now))
+
+	// a token every 20s.
+	assert.Equal(t, RateLimitResult{Allowed: false, Remaining: 0, Reset: 30 * time.Second, RetryAfter: 10 * time.Second}, take(now.Add(10*time.Second)))
+	assert.True(t, take(now.Add(20*time.Second)).Allowed)
+	assert.False(t, take(now.Add(20*time.Second)).Allowed)
+
+	// the bucket does not fill over its capacity.
+	assert.Equal(t, RateLimitResult{Allowed: true, Remaining: 1, Reset: 20 * time.Second}, take(now.Add(time.Hour)))
+
+	// other keys have their own bucket.
+	res, err := store.Take(t.Context(), "other", l, now)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	// idle keys are evicted once their bucket is full again.
+	store.evict(now.Add(2 * time.Hour))
+	for i := range store.shards {
+		assert.Empty(t, store.shards[i].entries)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	t.Parallel()
+
+	stores := map[string]func(t *testing.T) RateLimitStore{
+		"memory": func(t *testing.T) RateLimitStore {
+			t.Helper()
+			return NewMemoryRateLimitStore(t.Context(), time.Minute)
+		},
+		"redis": func(*testing.T) RateLimitStore { return NewRedisRateLimitStore(newFakeRedis(), "test:") },
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			store := newStore(t)
+			l := RateLimit{Algorithm: RateLimitSlidingWindow, Requests: 4, Window: time.Minute}
+			start := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC) // the start of a window.
+
+			take := func(at time.Time) RateLimitResult {
+				res, err := store.Take(t.Context(), "k", l, at)
+				require.NoError(t, err)
+				return res
+			}
+
+			for i := range 4 {
+				assert.Equal(t, RateLimitResult{Allowed: true, Remaining: 3 - i, Reset: 30 * time.Second}, take(start.Add(30*time.Second)))
+			}
+			assert.Equal(t, RateLimitResult{Allowed: false, Reset: 30 * time.Second, RetryAfter: 30 * time.Second}, take(start.Add(30*time.Second)))
+
+			// at the start of the next window the previous one is counted whole; 15s later, 3 of its 4 requests.
+			next := start.Add(time.Minute)
+			assert.Equal(t, RateLimitResult{Allowed: false, Reset: time.Minute, RetryAfter: 15 * time.Second}, take(next))
+			assert.Equal(t, RateLimitResult{Allowed: true, Remaining: 0, Reset: 45 * time.Second}, take(next.Add(15*time.Second)))
+
+			// a window later, the count starts over.
+			assert.Equal(t, RateLimitResult{Allowed: true, Remaining: 3, Reset: time.Minute}, take(start.Add(3*time.Minute)))
+		})
+	}
+
+	// the redis counters expire once they are no longer the previous window.
+	client := newFakeRedis()
+	store := NewRedisRateLimitStore(client, "test:")
+	_, err := store.Take(t.Context(), "k", RateLimit{Algorithm: RateLimitSlidingWindow, Requests: 1, Window: time.Minute}, time.Unix(120, 0))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{"test:k:2": 2 * time.Minute}, client.ttls)
+
+	_, err = store.Take(t.Context(), "k", RateLimit{Algorithm: RateLimitTokenBucket, Requests: 1, Window: time.Minute}, time.Unix(120, 0))
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+func TestMemoryRateLimitStoreConcurrency(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryRateLimitStore(t.Context(), time.Minute)
+	now := time.Now()
+
+	for _, algorithm := range []string{RateLimitTokenBucket, RateLimitSlidingWindow} {
+		l := RateLimit{Algorithm: algorithm, Requests: 50, Window: time.Hour}
+		allowed := atomic.Int64{}
+		wg := sync.WaitGroup{}
+		for i := range 200 {
+			wg.Go(func() {
+				res, err := store.Take(t.Context(), algorithm+strconv.Itoa(i%2), l, now)
+				assert.NoError(t, err)
+				if res.Allowed {
+					allowed.Add(1)
+				}
+			})
+		}
+		wg.Wait()
+
+		assert.Equal(t, int64(100), allowed.Load(), algorithm)
+	}
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	t.Parallel()
+
+	newRouter := func(t *testing.T, c RateLimitConfig, store RateLimitStore) *chi.Mux {
+		t.Helper()
+
+		router := chi.NewRouter()
+		router.Use(RealIP([]netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")})) // the RemoteAddr of httptest requests.
+		router.Use(NewRateLimiter(c, store).Middleware)
+		router.Get("/items/{id}", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+		router.Get("/other", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+		return router
+	}
+
+	do := func(t *testing.T, h http.Handler, target, ip string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, target, nil)
+		req.Header.Set("X-Real-IP", ip)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("headers", func(t *testing.T) {
+		t.Parallel()
+
+		c := RateLimitConfig{Algorithm: RateLimitTokenBucket, Key: RateLimitKeyIP, Requests: 2, Window: time.Minute}
+		router := newRouter(t, c, NewMemoryRateLimitStore(t.Context(), time.Minute))
+
+		resp := do(t, router, "/items/1", "10.0.0.1")
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		assert.Equal(t, "2;w=60;burst=2", resp.Header().Get("RateLimit-Policy"))
+		assert.Equal(t, "2", resp.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", resp.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "30", resp.Header().Get("RateLimit-Reset"))
+		assert.Empty(t, resp.Header().Get("Retry-After"))
+
+		assert.Equal(t, http.StatusNoContent, do(t, router, "/other", "10.0.0.1").Code)
+
+		resp = do(t, router, "/items/2", "10.0.0.1")
+		assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+		assert.Equal(t, ProblemContentType, resp.Header().Get("Content-Type"))
+		assert.Equal(t, "0", resp.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "30", resp.Header().Get("Retry-After"))
+
+		// per ip.
+		assert.Equal(t, http.StatusNoContent, do(t, router, "/items/1", "10.0.0.2").Code)
+	})
+
+	t.Run("per route", func(t *testing.T) {
+		t.Parallel()
+
+		c := RateLimitConfig{Algorithm: RateLimitSlidingWindow, Key: RateLimitKeyRoute, Requests: 1, Window: time.Minute}
+		router := newRouter(t, c, NewMemoryRateLimitStore(t.Context(), time.Minute))
+
+		assert.Equal(t, http.StatusNoContent, do(t, router, "/items/1", "10.0.0.1").Code)
+		assert.Equal(t, http.StatusTooManyRequests, do(t, router, "/items/2", "10.0.0.2").Code)
+		assert.Equal(t, http.StatusNoContent, do(t, router, "/other", "10.0.0.1").Code)
+	})
+
+	t.Run("per principal", func(t *testing.T) {
+		t.Parallel()
+
+		c := RateLimitConfig{Algorithm: RateLimitTokenBucket, Key: RateLimitKeyPrincipal, Requests: 1, Window: time.Minute}
+		limited := NewRateLimiter(c, NewMemoryRateLimitStore(t.Context(), time.Minute)).Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		handler := newTestAuthenticators(t).Require(AuthBasic, AuthAPIKey)(limited)
+
+		send := func(user string) int {
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			if user == "" {
+				req.Header.Set("X-API-Key", "key-1")
+			} else {
+				req.SetBasicAuth(user, "secret")
+			}
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			return resp.Code
+		}
+
+		assert.Equal(t, http.StatusNoContent, send("yoda"))
+		assert.Equal(t, http.StatusTooManyRequests, send("yoda"))
+		assert.Equal(t, http.StatusNoContent, send("")) // the api key of ci, from the same ip.
+	})
+
+	t.Run("store errors let requests through", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeRedis()
+		client.err = errors.New("connection refused")
+		c := RateLimitConfig{Algorithm: RateLimitSlidingWindow, Key: RateLimitKeyIP, Requests: 1, Window: time.Minute}
+		router := newRouter(t, c, NewRedisRateLimitStore(client, ""))
+
+		for range 3 {
+			resp := do(t, router, "/items/1", "10.0.0.1")
+			assert.Equal(t, http.StatusNoContent, resp.Code)
+			assert.Empty(t, resp.Header().Get("RateLimit-Remaining"))
+		}
+	})
+}
+
+func TestDefaultRouterRateLimitPerPrincipal(t *testing.T) {
+	t.Parallel()
+
+	c := Config{
+		RateLimit: RateLimitConfig{Enabled: true, Algorithm: RateLimitTokenBucket, Key: RateLimitKeyPrincipal, Requests: 2, Window: time.Minute},
+		Authz:     AuthzConfig{SubjectRoles: map[string]map[string][]string{AuthBasic: {"yoda": {"admin"}}}, RoleScopes: map[string][]string{"admin": {"echo"}}},
+	}
+	router := NewDefaultRouter(t.Context(), c, newTestAuthenticators(t), nil, slog.Default())
+
+	do := func(target, ip, password string) int {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, target, nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("X-Real-IP", "192.0.2.1") // ignored, no proxy is trusted.
+		if password != "" {
+			req.SetBasicAuth("yoda", password)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	// failed attempts are limited per ip, ahead of the authentication.
+	assert.Equal(t, http.StatusUnauthorized, do("/echo", "10.0.0.1", "wrong"))
+	assert.Equal(t, http.StatusUnauthorized, do("/echo", "10.0.0.1", "wrong"))
+	assert.Equal(t, http.StatusTooManyRequests, do("/echo", "10.0.0.1", "wrong"))
+	assert.Equal(t, http.StatusTooManyRequests, do("/echo", "10.0.0.1", "secret"))
+
+	// so are the routes without authentication.
+	assert.Equal(t, http.StatusNotFound, do("/missing", "10.0.0.2", ""))
+	assert.Equal(t, http.StatusNotFound, do("/missing", "10.0.0.2", ""))
+	assert.Equal(t, http.StatusTooManyRequests, do("/missing", "10.0.0.2", ""))
+
+	// the authenticated requests per principal, whatever their ip.
+	assert.Equal(t, http.StatusOK, do("/echo", "10.0.0.3", "secret"))
+	assert.Equal(t, http.StatusOK, do("/echo", "10.0.0.4", "secret"))
+	assert.Equal(t, http.StatusTooManyRequests, do("/echo", "10.0.0.5", "secret"))
+}
@@ -0,0 +1,125 @@
+package zhttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+)
+
+// maxPooledBufferSize keeps the buffers of large responses out of the pool.
+const maxPooledBufferSize = 64 << 10
+
+//nolint:gochecknoglobals
+var bufferPool = sync.Pool{New: func() any { return &bytes.Buffer{} }}
+
+type respondOptions struct {
+	buffered bool
+	etag     *http.Request // the request whose If-None-Match is answered; nil disables the ETag.
+}
+
+// RespondOption configures RespondJSON and Respond.
+type RespondOption func(*respondOptions)
+
+// Buffered encodes the body into a (pooled) buffer before anything is written, so an encoding error becomes a
+// proper 500 problem response and the response gets a Content-Length. Without it the body is streamed
+// (usually chunked), which suits large responses.
+func Buffered() RespondOption {
+	return func(o *respondOptions) { o.buffered = true }
+}
+
+// WithETag sets a strong ETag computed from the body and answers the If-None-Match of r with 304 Not Modified
+// (GET and HEAD 200 responses only). The response is revalidated on every use (Cache-Control: no-cache).
+// It implies Buffered.
+func WithETag(r *http.Request) RespondOption {
+	return func(o *respondOptions) {
+		o.buffered = true
+		o.etag = r
+	}
+}
+
+func respond(ctx context.Context, w http.ResponseWriter, statusCode int, c Codec, contentType string, body any, opts []RespondOption) {
+	o := respondOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if !o.buffered {
+		w.Header().Set(`Content-Type`, contentType)
+		w.Header().Add(`Cache-Control`, `no-store`) // https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Cache-Control
+		w.WriteHeader(statusCode)
+
+		if body != nil {
+			if err := c.Encode(w, body); err != nil {
+				zlog.GetFromContext(ctx).ErrorContext(ctx, "error during response encoding", zlog.Error(err))
+			}
+		}
+		return
+	}
+
+	buf, _ := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBufferSize {
+			bufferPool.Put(buf)
+		}
+	}()
+
+	if body != nil {
+		if err := c.Encode(buf, body); err != nil {
+			RespondError(ctx, w, fmt.Errorf("response encoding: %w", err))
+			return
+		}
+	}
+
+	h := w.Header()
+	h.Set(`Content-Type`, contentType)
+
+	if o.etag != nil && body != nil {
+		etag := strongETag(buf.Bytes())
+		h.Set(`ETag`, etag)
+		h.Set(`Cache-Control`, `no-cache`)
+
+		r := o.etag
+		if statusCode == http.StatusOK && (r.Method == http.MethodGet || r.Method == http.MethodHead) && etagMatches(r.Header.Values(`If-None-Match`), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else {
+		h.Set(`Cache-Control`, `no-store`)
+	}
+
+	h.Set(`Content-Length`, strconv.Itoa(buf.Len()))
+	w.WriteHeader(statusCode)
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		zlog.GetFromContext(ctx).DebugContext(ctx, "error during response write", zlog.Error(err))
+	}
+}
+
+// strongETag returns the quoted ETag of body (the first 128 bits of its sha256).
+func strongETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header values match etag, using the weak comparison (RFC 9110 13.1.2).
+func etagMatches(ifNoneMatch []string, etag string) bool {
+	for _, v := range ifNoneMatch {
+		for candidate := range strings.SplitSeq(v, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,108 @@
+package zhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRespondJSONBuffered(t *testing.T) {
+	t.Parallel()
+
+	resp := httptest.NewRecorder()
+	RespondJSON(t.Context(), resp, http.StatusCreated, map[string]string{"a": "b"}, Buffered())
+
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	assert.Equal(t, "application/json; charset=utf-8", resp.Header().Get("Content-Type"))
+	assert.Equal(t, "no-store", resp.Header().Get("Cache-Control"))
+	assert.Equal(t, strconv.Itoa(len("{\"a\":\"b\"}\n")), resp.Header().Get("Content-Length"))
+	assert.Empty(t, resp.Header().Get("ETag"))
+	assert.JSONEq(t, `{"a":"b"}`, resp.Body.String())
+
+	// streaming (default) cannot report the error of a partially written body.
+	resp = httptest.NewRecorder()
+	RespondJSON(t.Context(), resp, http.StatusOK, map[string]any{"f": func() {}})
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = httptest.NewRecorder()
+	RespondJSON(t.Context(), resp, http.StatusOK, map[string]any{"f": func() {}}, Buffered())
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Equal(t, ProblemContentType, resp.Header().Get("Content-Type"))
+}
+
+func TestRespondETag(t *testing.T) {
+	t.Parallel()
+
+	body := map[string]string{"a": "b"}
+	etag := func() string {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+		resp := httptest.NewRecorder()
+		RespondJSON(t.Context(), resp, http.StatusOK, body, WithETag(req))
+		require.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "no-cache", resp.Header().Get("Cache-Control"))
+		assert.NotEmpty(t, resp.Header().Get("Content-Length"))
+		return resp.Header().Get("ETag")
+	}()
+	require.NotEmpty(t, etag)
+	assert.Regexp(t, `^"[A-Za-z0-9_-]+"$`, etag)
+
+	tests := map[string]struct {
+		method       string
+		status       int
+		ifNoneMatch  []string
+		expectedCode int
+	}{
+		"no condition":     {method: http.MethodGet, status: http.StatusOK, expectedCode: http.StatusOK},
+		"match":            {method: http.MethodGet, status: http.StatusOK, ifNoneMatch: []string{etag}, expectedCode: http.StatusNotModified},
+		"weak match":       {method: http.MethodGet, status: http.StatusOK, ifNoneMatch: []string{"W/" + etag}, expectedCode: http.StatusNotModified},
+		"list match":       {method: http.MethodGet, status: http.StatusOK, ifNoneMatch: []string{`"x", ` + etag}, expectedCode: http.StatusNotModified},
+		"multiple headers": {method: http.MethodGet, status: http.StatusOK, ifNoneMatch: []string{`"x"`, etag}, expectedCode: http.StatusNotModified},
+		"any":              {method: http.MethodGet, status: http.StatusOK, ifNoneMatch: []string{"*"}, expectedCode: http.StatusNotModified},
+		"head":             {method: http.MethodHead, status: http.StatusOK, ifNoneMatch: []string{etag}, expectedCode: http.StatusNotModified},
+		"no match":         {method: http.MethodGet, status: http.StatusOK, ifNoneMatch: []string{`"x"`}, expectedCode: http.StatusOK},
+		"not get":          {method: http.MethodPost, status: http.StatusOK, ifNoneMatch: []string{etag}, expectedCode: http.StatusOK},
+		"not ok":           {method: http.MethodGet, status: http.StatusCreated, ifNoneMatch: []string{etag}, expectedCode: http.StatusCreated},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequestWithContext(t.Context(), tc.method, "/", nil)
+			for _, v := range tc.ifNoneMatch {
+				req.Header.Add("If-None-Match", v)
+			}
+			resp := httptest.NewRecorder()
+			RespondJSON(t.Context(), resp, tc.status, body, WithETag(req))
+
+			assert.Equal(t, tc.expectedCode, resp.Code)
+			assert.Equal(t, etag, resp.Header().Get("ETag"))
+			if tc.expectedCode == http.StatusNotModified {
+				assert.Empty(t, resp.Body.String())
+				assert.Empty(t, resp.Header().Get("Content-Length"))
+			}
+		})
+	}
+}
+
+func TestRespondNegotiatedETag(t *testing.T) {
+	t.Parallel()
+
+	// the ETag is of the negotiated representation.
+	etags := map[string]string{}
+	for _, accept := range []string{"application/json", "application/yaml"} {
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+		req.Header.Set("Accept", accept)
+		resp := httptest.NewRecorder()
+		Respond(t.Context(), resp, req, http.StatusOK, map[string]string{"a": "b"}, WithETag(req))
+
+		require.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, strconv.Itoa(resp.Body.Len()), resp.Header().Get("Content-Length"))
+		etags[accept] = resp.Header().Get("ETag")
+	}
+	assert.NotEqual(t, etags["application/json"], etags["application/yaml"])
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package zhttp
+
+import (
+	"errors"
+	"os"
+)
+
+//nolint:gochecknoglobals
+var restartSignals []os.Signal
+
+func signalParentShutdown(_ int) error {
+	return errors.ErrUnsupported
+}
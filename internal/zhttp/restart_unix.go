@@ -0,0 +1,15 @@
+//go:build unix
+
+package zhttp
+
+import (
+	"os"
+	"syscall"
+)
+
+//nolint:gochecknoglobals
+var restartSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR2}
+
+func signalParentShutdown(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
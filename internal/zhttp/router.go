@@ -2,70 +2,188 @@ package zhttp
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"expvar"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	xhttp "github.com/ifnotnil/x/http"
-	"github.com/knadh/koanf/v2"
+	"github.com/moukoublen/goboilerplate/build"
 	"github.com/moukoublen/goboilerplate/internal/zlog"
 )
 
-func DefaultConfigValues() map[string]any {
-	return map[string]any{
-		"http.ip":   "0.0.0.0",
-		"http.port": "8888",
-	}
+type Config struct {
+	ListenerConfig
+
+	GlobalInboundTimeout time.Duration          `koanf:"global_inbound_timeout" default:"0s"       validate:"min=0" desc:"Timeout of every inbound request. 0 disables it."`
+	MaxBodyBytes         int64                  `koanf:"max_body_bytes"         default:"10485760" validate:"min=0" desc:"The maximum size of a request body. 0 means no limit."`
+	DebugEndpoints       bool                   `koanf:"debug_endpoints"        default:"false"                     desc:"Serves debug endpoints (/debug/config, /debug/pprof) on the admin server."`
+	GracefulRestart      bool                   `koanf:"graceful_restart"       default:"false"                     desc:"On SIGHUP or SIGUSR2 re-executes the binary, handing off the listening socket, and drains the old process once the new one serves."`
+	TrustedProxies       []netip.Prefix         `koanf:"trusted_proxies"                                            desc:"The CIDR ranges (e.g. 10.0.0.0/8, 192.0.2.1/32) of the reverse proxies whose X-Forwarded-For and X-Real-IP headers are trusted for the client ip. The headers of other peers are ignored; empty means the client ip is always the socket peer."`
+	TLS                  TLSConfig              `koanf:"tls"`
+	HTTP3                HTTP3Config            `koanf:"http3"`
+	Compression          CompressionConfig      `koanf:"compression"`
+	OpenAPI              OpenAPIConfig          `koanf:"openapi"`
+	Auth                 AuthConfig             `koanf:"auth"`
+	Authz                AuthzConfig            `koanf:"authz"`
+	RateLimit            RateLimitConfig        `koanf:"rate_limit"`
+	ConcurrencyLimit     ConcurrencyLimitConfig `koanf:"concurrency_limit"`
+	CORS                 CORSConfig             `koanf:"cors"`
+	SecurityHeaders      SecurityHeadersConfig  `koanf:"security_headers"`
+
+	// operational endpoints are served only by the admin and metrics servers, never by the public one.
+	Admin   AdminConfig   `koanf:"admin"`
+	Metrics MetricsConfig `koanf:"metrics"`
 }
 
-type Config struct {
-	IP                   string
-	Port                 int64
-	GlobalInboundTimeout time.Duration
-	ReadHeaderTimeout    time.Duration
+// ConfigDefaults returns the defaults of the public server.
+func (Config) ConfigDefaults() map[string]string {
+	return map[string]string{"ip": "0.0.0.0", "port": "8888", "max_connections": "10000"}
 }
 
-func ParseConfig(cnf *koanf.Koanf) Config {
-	return Config{
-		IP:                   cnf.String("http.ip"),
-		Port:                 cnf.Int64("http.port"),
-		GlobalInboundTimeout: cnf.Duration("http.global_inbound_timeout"),
-		ReadHeaderTimeout:    cnf.Duration("http.read_header_timeout"),
-	}
+// NewDefaultRouter returns the public *chi.Mux with a default set of middlewares.
+// The protected routes accept the enabled authenticators of auths (see NewAuthenticators). If limiter is not nil,
+// the requests over its concurrency limit are shed, after the CORS and security headers are set.
+func NewDefaultRouter(ctx context.Context, c Config, auths Authenticators, limiter *ConcurrencyLimiter, logger *slog.Logger) *chi.Mux {
+	router, _ := NewDefaultAPI(ctx, c, auths, limiter, logger)
+
+	return router
 }
 
-// NewDefaultRouter returns a *chi.Mux with a default set of middlewares and an "/about" route.
-func NewDefaultRouter(ctx context.Context, c Config, logger *slog.Logger) *chi.Mux {
+// NewDefaultAPI returns the public *chi.Mux (see NewDefaultRouter) along with the API its routes are registered
+// through, which describes them in its OpenAPI document.
+func NewDefaultAPI(ctx context.Context, c Config, auths Authenticators, limiter *ConcurrencyLimiter, logger *slog.Logger) (*chi.Mux, *API) {
 	router := chi.NewRouter()
+	router.NotFound(NotFoundHandler)
+	router.MethodNotAllowed(MethodNotAllowedHandler)
 
 	router.Use(middleware.Heartbeat("/ping"))
 	router.Use(middleware.RequestID)
-	router.Use(middleware.RealIP)
+	router.Use(RealIP(c.TrustedProxies))
+	router.Use(ClientIdentityMiddleware)
 
-	router.Use(middleware.Recoverer)
+	router.Use(Recoverer)
+
+	// ahead of the rate limit and the authentication, so that their errors are readable by browsers, and preflights,
+	// which carry no credentials, are answered.
+	if c.SecurityHeaders.Enabled {
+		router.Use(SecurityHeaders(c.SecurityHeaders))
+	}
+	if c.CORS.Enabled {
+		router.Use(CORS(c.CORS))
+	}
+
+	// shed ahead of any work, but with the CORS and security headers and the request id of the response.
+	if limiter != nil {
+		router.Use(limiter.Middleware)
+	}
+
+	var principalLimiter *RateLimiter
+	if c.RateLimit.Enabled {
+		store := NewMemoryRateLimitStore(ctx, max(c.RateLimit.Window, time.Minute))
+		// the principal is known on the authenticated routes only, after the authentication; every request is limited
+		// per ip ahead of it, so that the routes without authentication and the failed attempts are limited too.
+		limit := c.RateLimit
+		if limit.Key == RateLimitKeyPrincipal {
+			principalLimiter = NewRateLimiter(limit, store)
+			limit.Key = RateLimitKeyIP
+		}
+		router.Use(NewRateLimiter(limit, store).Middleware)
+	}
+
+	if c.MaxBodyBytes > 0 {
+		router.Use(MaxBodySize(c.MaxBodyBytes))
+	}
+
+	if c.Compression.DecompressRequests {
+		router.Use(Decompress(c.Compression.MaxDecompressedBytes))
+	}
+
+	if c.Compression.Enabled {
+		router.Use(Compress(ctx, c.Compression))
+	}
 
 	if c.GlobalInboundTimeout > 0 {
-		router.Use(middleware.Timeout(c.GlobalInboundTimeout))
+		router.Use(Timeout(c.GlobalInboundTimeout))
+	}
+
+	api := NewAPI(router, OpenAPIInfo{Title: "goboilerplate", Version: build.GetInfo().Version})
+	for name, s := range c.Auth.SecuritySchemes() {
+		api.AddSecurityScheme(name, s)
 	}
 
-	router.Group(func(r chi.Router) {
-		r.Use(middleware.BasicAuth("", map[string]string{
-			"Yoda": "_Named must your fear be before banish it you can_",
-		}))
-		r.Get("/echo", xhttp.EchoHandler(logger))
+	policy := NewPolicy(c.Authz)
+
+	api.Group(func(api *API) {
+		api.Authenticate(auths, AuthBasic, AuthAPIKey, AuthJWT)
+		if principalLimiter != nil {
+			api.Use(principalLimiter.Middleware)
+		}
+		api.Authorize(policy, Requirement{Scopes: []string{"echo"}})
+		api.Method(http.MethodGet, "/echo", http.HandlerFunc(xhttp.EchoHandler(logger)), WithSummary("Echoes the request back."), WithTags("debug"))
 	})
 
-	router.Get("/about", AboutHandler)
+	if c.OpenAPI.Enabled {
+		router.Get("/openapi.json", OpenAPIHandler(api))
+		if c.OpenAPI.DocsUI {
+			router.Mount("/docs", DocsHandler("/docs"))
+		}
+	}
 
 	// for test purposes
 	// router.Get("/panic", func(_ http.ResponseWriter, _ *http.Request) { panic("test panic") })
 
 	LogRoutes(ctx, router)
 
+	return router, api
+}
+
+// NewAdminRouter returns the *chi.Mux of the admin server, with the "/about" route and,
+// if c.DebugEndpoints, the pprof and expvar endpoints under "/debug". If limiter is not nil, the requests over its
+// concurrency limit are shed.
+func NewAdminRouter(ctx context.Context, c Config, limiter *ConcurrencyLimiter) *chi.Mux {
+	router := chi.NewRouter()
+	router.NotFound(NotFoundHandler)
+	router.MethodNotAllowed(MethodNotAllowedHandler)
+
+	router.Use(middleware.Heartbeat("/ping"))
+	router.Use(middleware.RequestID)
+	router.Use(Recoverer)
+	if limiter != nil {
+		router.Use(limiter.Middleware)
+	}
+
+	router.Get("/about", AboutHandler)
+
+	if c.DebugEndpoints {
+		router.Mount("/debug", middleware.Profiler())
+	}
+
+	LogRoutes(ctx, router)
+
+	return router
+}
+
+// NewMetricsRouter returns the *chi.Mux of the metrics server, which serves the expvar variables at "/metrics".
+func NewMetricsRouter(ctx context.Context) *chi.Mux {
+	router := chi.NewRouter()
+	router.NotFound(NotFoundHandler)
+	router.MethodNotAllowed(MethodNotAllowedHandler)
+
+	router.Use(middleware.Heartbeat("/ping"))
+	router.Use(Recoverer)
+
+	router.Method(http.MethodGet, "/metrics", expvar.Handler())
+
+	LogRoutes(ctx, router)
+
 	return router
 }
 
@@ -91,18 +209,51 @@ func LogRoutes(ctx context.Context, r *chi.Mux) {
 	}
 }
 
-// StartListenAndServe creates and runs server.ListenAndServe in a separate go routine.
-// Any error produced by ListenAndServe will be sent to fatalErrCh.
+// ServerOptions are the http.Server timeouts and limits, along with the TLS config (nil serves plain http).
+type ServerOptions struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	MaxConnections    int  // 0 means no limit.
+	H2C               bool // HTTP/2 cleartext; it applies only when TLSConfig is nil.
+	TLSConfig         *tls.Config
+}
+
+// StartListenAndServe creates and runs server.Serve (or server.ServeTLS when opts.TLSConfig is not nil) over ln in a separate go routine.
+// Any error produced by Serve will be sent to fatalErrCh.
 // It returns the server struct.
-func StartListenAndServe(addr string, handler http.Handler, readHeaderTimeout time.Duration, fatalErrCh chan<- error) *http.Server {
+func StartListenAndServe(ln net.Listener, handler http.Handler, opts ServerOptions, fatalErrCh chan<- error) *http.Server {
 	server := &http.Server{
-		Addr:              addr,
+		Addr:              ln.Addr().String(),
 		Handler:           handler,
-		ReadHeaderTimeout: readHeaderTimeout,
+		ReadHeaderTimeout: opts.ReadHeaderTimeout,
+		ReadTimeout:       opts.ReadTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+		MaxHeaderBytes:    opts.MaxHeaderBytes,
+		TLSConfig:         opts.TLSConfig,
+	}
+
+	if opts.H2C && opts.TLSConfig == nil {
+		server.Protocols = new(http.Protocols)
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	if opts.MaxConnections > 0 {
+		ln = newLimitListener(ln, opts.MaxConnections)
+	}
+
+	serve := server.Serve
+	if opts.TLSConfig != nil {
+		// certificates are provided by TLSConfig.GetCertificate.
+		serve = func(ln net.Listener) error { return server.ServeTLS(ln, "", "") }
 	}
 
 	go func() {
-		if err := server.ListenAndServe(); err != nil {
+		if err := serve(ln); err != nil {
 			if !errors.Is(err, http.ErrServerClosed) {
 				fatalErrCh <- err
 			}
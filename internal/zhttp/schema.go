@@ -0,0 +1,265 @@
+package zhttp
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// descTag is the struct tag of the field descriptions (as in the config structs).
+const descTag = "desc"
+
+//nolint:gochecknoglobals
+var (
+	timeType            = reflect.TypeFor[time.Time]()
+	rawMessageType      = reflect.TypeFor[json.RawMessage]()
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	componentNameRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// schemaGenerator generates the JSON Schemas (draft 2020-12, the dialect of OpenAPI 3.1) of go types, the way
+// encoding/json renders them. Named struct types become components, referenced with $ref.
+type schemaGenerator struct {
+	components map[string]map[string]any
+	names      map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		components: map[string]map[string]any{},
+		names:      map[reflect.Type]string{},
+	}
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == durationType:
+		return map[string]any{"type": "integer", "format": "int64", "description": "Duration in nanoseconds."}
+	case t == rawMessageType:
+		return map[string]any{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() { //nolint:exhaustive
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem()), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return g.ref(t)
+	default: // interfaces
+		return map[string]any{}
+	}
+}
+
+// ref returns the $ref to the component of the named type t, generating it the first time.
+func (g *schemaGenerator) ref(t reflect.Type) map[string]any {
+	name, found := g.names[t]
+	if !found {
+		name = g.componentName(t)
+		g.names[t] = name
+		g.components[name] = map[string]any{} // reserved, for recursive types.
+		g.components[name] = g.structSchema(t)
+	}
+
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// componentName returns a unique component name for t; the package name disambiguates types of the same name.
+func (g *schemaGenerator) componentName(t reflect.Type) string {
+	candidates := []string{
+		componentNameRegexp.ReplaceAllString(t.Name(), "_"),
+		componentNameRegexp.ReplaceAllString(path.Base(t.PkgPath())+"."+t.Name(), "_"),
+	}
+	for _, c := range candidates {
+		if _, taken := g.components[c]; !taken {
+			return c
+		}
+	}
+
+	for i := 2; ; i++ {
+		c := candidates[1] + strconv.Itoa(i)
+		if _, taken := g.components[c]; !taken {
+			return c
+		}
+	}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	g.structFields(t, props, &required)
+
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+
+	return s
+}
+
+func (g *schemaGenerator) structFields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			g.structFields(ft, props, required)
+			continue
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		s := g.schema(sf.Type)
+		if d := sf.Tag.Get(descTag); d != "" {
+			s["description"] = d
+		}
+		if applyRules(s, sf.Tag.Get("validate")) {
+			*required = append(*required, name)
+		}
+		props[name] = s
+	}
+}
+
+// paramSchema returns the schema of a path, query or header parameter field (see Bind).
+func (g *schemaGenerator) paramSchema(sf reflect.StructField) (map[string]any, bool) {
+	var typeSchema func(t reflect.Type) map[string]any
+	typeSchema = func(t reflect.Type) map[string]any {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch {
+		case t == durationType:
+			return map[string]any{"type": "string", "format": "duration", "examples": []string{"1m30s"}}
+		case t == timeType:
+			return map[string]any{"type": "string", "format": "date-time"}
+		case reflect.PointerTo(t).Implements(textUnmarshalerType):
+			return map[string]any{"type": "string"}
+		case t.Kind() == reflect.Slice:
+			return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+		}
+		return g.schema(t)
+	}
+
+	s := typeSchema(sf.Type)
+	if d, found := sf.Tag.Lookup("default"); found {
+		s["default"] = typedParamDefault(sf.Type, d)
+	}
+	required := applyRules(s, sf.Tag.Get("validate"))
+
+	return s, required
+}
+
+// typedParamDefault converts the default tag value d of a parameter of type t, for the schema.
+func typedParamDefault(t reflect.Type, d string) any {
+	if t == durationType || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return d
+	}
+
+	v := reflect.New(t).Elem()
+	if err := setParam(v, []string{d}); err != nil {
+		return d
+	}
+
+	return v.Interface()
+}
+
+// applyRules adds the `validate` rules to the schema s and reports whether the field is required.
+func applyRules(s map[string]any, rules string) bool {
+	required := false
+	typ, _ := s["type"].(string)
+
+	keyword := func(rule string) string {
+		switch typ {
+		case "integer", "number":
+			return map[string]string{"min": "minimum", "gte": "minimum", "max": "maximum", "lte": "maximum", "gt": "exclusiveMinimum", "lt": "exclusiveMaximum"}[rule]
+		case "string":
+			return map[string]string{"min": "minLength", "gte": "minLength", "max": "maxLength", "lte": "maxLength"}[rule]
+		case "array":
+			return map[string]string{"min": "minItems", "gte": "minItems", "max": "maxItems", "lte": "maxItems"}[rule]
+		case "object":
+			return map[string]string{"min": "minProperties", "gte": "minProperties", "max": "maxProperties", "lte": "maxProperties"}[rule]
+		}
+		return ""
+	}
+
+	for rule := range strings.SplitSeq(rules, ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch rule {
+		case "required":
+			required = true
+			switch typ {
+			case "string":
+				s["minLength"] = 1
+			case "array":
+				s["minItems"] = 1
+			}
+		case "oneof":
+			enum := []any{}
+			for _, v := range strings.Fields(param) {
+				if n, err := strconv.ParseFloat(v, 64); err == nil && (typ == "integer" || typ == "number") {
+					enum = append(enum, n)
+				} else {
+					enum = append(enum, v)
+				}
+			}
+			s["enum"] = enum
+		case "min", "max", "gt", "gte", "lt", "lte":
+			k := keyword(rule)
+			if k == "" {
+				continue
+			}
+			if n, err := strconv.ParseFloat(param, 64); err == nil {
+				s[k] = n
+			}
+		}
+	}
+
+	return required
+}
@@ -0,0 +1,63 @@
+package zhttp
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type schemaTestBase struct {
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type schemaTestNode struct {
+	schemaTestBase
+
+	Name     string            `json:"name"               validate:"required,max=10" desc:"The node name."`
+	Kind     string            `json:"kind,omitempty"     validate:"oneof=a b"`
+	Weight   float64           `json:"weight"             validate:"gt=0,lte=1"`
+	Tags     []string          `json:"tags"               validate:"max=3"`
+	Data     []byte            `json:"data,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Children []*schemaTestNode `json:"children,omitempty"`
+	Any      any               `json:"any,omitempty"`
+	Hidden   string            `json:"-"`
+	private  string            //nolint:unused // unexported fields are not documented.
+}
+
+func TestSchemaGenerator(t *testing.T) {
+	t.Parallel()
+
+	g := newSchemaGenerator()
+	assert.Equal(t, map[string]any{"$ref": "#/components/schemas/schemaTestNode"}, g.schema(reflect.TypeFor[*schemaTestNode]()))
+
+	assert.Equal(t, map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"created_at": map[string]any{"type": "string", "format": "date-time"},
+			"name":       map[string]any{"type": "string", "minLength": 1, "maxLength": 10.0, "description": "The node name."},
+			"kind":       map[string]any{"type": "string", "enum": []any{"a", "b"}},
+			"weight":     map[string]any{"type": "number", "format": "double", "exclusiveMinimum": 0.0, "maximum": 1.0},
+			"tags":       map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "maxItems": 3.0},
+			"data":       map[string]any{"type": "string", "contentEncoding": "base64"},
+			"labels":     map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+			"children":   map[string]any{"type": "array", "items": map[string]any{"$ref": "#/components/schemas/schemaTestNode"}},
+			"any":        map[string]any{},
+		},
+		"required": []string{"name"},
+	}, g.components["schemaTestNode"])
+}
+
+func TestSchemaGeneratorComponentNames(t *testing.T) {
+	t.Parallel()
+
+	problemType := reflect.TypeFor[APIError]()
+	type APIError struct{} // a different type of the same name.
+
+	g := newSchemaGenerator()
+	assert.Equal(t, map[string]any{"$ref": "#/components/schemas/APIError"}, g.schema(problemType))
+	assert.Equal(t, map[string]any{"$ref": "#/components/schemas/zhttp.APIError"}, g.schema(reflect.TypeFor[APIError]()))
+	assert.Equal(t, map[string]any{"$ref": "#/components/schemas/APIError"}, g.schema(problemType))
+}
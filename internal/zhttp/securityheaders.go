@@ -0,0 +1,65 @@
+package zhttp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SecurityHeadersConfig struct {
+	Enabled               bool          `koanf:"enabled"                 default:"false"                                                                                                                desc:"Adds security headers to the responses of the public server."`
+	HSTSMaxAge            time.Duration `koanf:"hsts_max_age"            default:"8760h"                                                                           validate:"min=0"                     desc:"The max-age of Strict-Transport-Security, sent over https only (tls, or X-Forwarded-Proto: https). 0 disables it."`
+	HSTSIncludeSubdomains bool          `koanf:"hsts_include_subdomains" default:"false"                                                                                                                desc:"Applies Strict-Transport-Security to the subdomains too."`
+	HSTSPreload           bool          `koanf:"hsts_preload"            default:"false"                                                                                                                desc:"Allows the inclusion in the HSTS preload lists of browsers."`
+	ContentTypeNosniff    bool          `koanf:"content_type_nosniff"    default:"true"                                                                                                                 desc:"Sends X-Content-Type-Options: nosniff."`
+	FrameOptions          string        `koanf:"frame_options"           default:"deny"                                                                            validate:"oneof=deny sameorigin off" desc:"The X-Frame-Options."`
+	ContentSecurityPolicy string        `koanf:"content_security_policy" default:"default-src 'self'; frame-ancestors 'none'; base-uri 'none'; form-action 'self'"                                      desc:"The Content-Security-Policy. Empty disables it."`
+	ReferrerPolicy        string        `koanf:"referrer_policy"         default:"strict-origin-when-cross-origin"                                                                                      desc:"The Referrer-Policy. Empty disables it."`
+}
+
+// SecurityHeaders returns a middleware that adds the security headers of c to every response, errors included.
+// Handlers may override them, e.g. a looser Content-Security-Policy for a page.
+func SecurityHeaders(c SecurityHeadersConfig) func(http.Handler) http.Handler {
+	headers := map[string]string{}
+
+	if c.ContentTypeNosniff {
+		headers["X-Content-Type-Options"] = "nosniff"
+	}
+	if c.FrameOptions != "off" {
+		headers["X-Frame-Options"] = strings.ToUpper(c.FrameOptions)
+	}
+	if c.ContentSecurityPolicy != "" {
+		headers["Content-Security-Policy"] = c.ContentSecurityPolicy
+	}
+	if c.ReferrerPolicy != "" {
+		headers["Referrer-Policy"] = c.ReferrerPolicy
+	}
+
+	hsts := ""
+	if c.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(c.HSTSMaxAge.Seconds()))
+		if c.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if c.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			for k, v := range headers {
+				h.Set(k, v)
+			}
+
+			// browsers ignore Strict-Transport-Security over http (RFC 6797), so it is not sent there.
+			if hsts != "" && (r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")) {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package zhttp
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	t.Parallel()
+
+	c := SecurityHeadersConfig{
+		Enabled:               true,
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		ContentTypeNosniff:    true,
+		FrameOptions:          "sameorigin",
+		ContentSecurityPolicy: "default-src 'self'",
+		ReferrerPolicy:        "no-referrer",
+	}
+
+	tests := map[string]struct {
+		config   func(c SecurityHeadersConfig) SecurityHeadersConfig
+		request  func(r *http.Request)
+		expected map[string]string // empty values are expected to be absent.
+	}{
+		"http": {
+			expected: map[string]string{
+				"X-Content-Type-Options":    "nosniff",
+				"X-Frame-Options":           "SAMEORIGIN",
+				"Content-Security-Policy":   "default-src 'self'",
+				"Referrer-Policy":           "no-referrer",
+				"Strict-Transport-Security": "",
+			},
+		},
+		"tls": {
+			request:  func(r *http.Request) { r.TLS = &tls.ConnectionState{} },
+			expected: map[string]string{"Strict-Transport-Security": "max-age=31536000"},
+		},
+		"behind a tls proxy": {
+			config: func(c SecurityHeadersConfig) SecurityHeadersConfig {
+				c.HSTSIncludeSubdomains, c.HSTSPreload = true, true
+				return c
+			},
+			request:  func(r *http.Request) { r.Header.Set("X-Forwarded-Proto", "https") },
+			expected: map[string]string{"Strict-Transport-Security": "max-age=31536000; includeSubDomains; preload"},
+		},
+		"disabled": {
+			config: func(SecurityHeadersConfig) SecurityHeadersConfig {
+				return SecurityHeadersConfig{Enabled: true, FrameOptions: "off"}
+			},
+			request: func(r *http.Request) { r.TLS = &tls.ConnectionState{} },
+			expected: map[string]string{
+				"X-Content-Type-Options":    "",
+				"X-Frame-Options":           "",
+				"Content-Security-Policy":   "",
+				"Referrer-Policy":           "",
+				"Strict-Transport-Security": "",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := c
+			if tc.config != nil {
+				config = tc.config(c)
+			}
+			handler := SecurityHeaders(config)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }))
+
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+			if tc.request != nil {
+				tc.request(req)
+			}
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusNoContent, resp.Code)
+			for k, v := range tc.expected {
+				assert.Equal(t, v, resp.Header().Get(k), k)
+			}
+		})
+	}
+}
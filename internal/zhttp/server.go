@@ -0,0 +1,138 @@
+package zhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// Names of the listeners (also used to match inherited sockets, see Listeners).
+const (
+	ListenerPublic  = "public"
+	ListenerAdmin   = "admin"
+	ListenerMetrics = "metrics"
+)
+
+// ListenerConfig is the config of the listener and the server of an http server. It is embedded by the config
+// sections of the servers (Config, AdminConfig and MetricsConfig), which set the defaults of their address and limits
+// (see config.Defaulter).
+type ListenerConfig struct {
+	IP                string        `koanf:"ip"                                    validate:"required"        desc:"The IP address the server binds to, or a unix socket (unix:///path.sock, or unix://@name for a linux abstract socket)."`
+	Port              int64         `koanf:"port"                                  validate:"min=0,max=65535" desc:"The port the server listens to."`
+	ReadHeaderTimeout time.Duration `koanf:"read_header_timeout" default:"5s"      validate:"min=0"           desc:"The amount of time allowed to read request headers. 0 means no timeout."`
+	ReadTimeout       time.Duration `koanf:"read_timeout"        default:"30s"     validate:"min=0"           desc:"The maximum duration for reading an entire request, including the body. 0 means no timeout."`
+	WriteTimeout      time.Duration `koanf:"write_timeout"       default:"30s"     validate:"min=0"           desc:"The maximum duration before timing out writes of the response. 0 means no timeout."`
+	IdleTimeout       time.Duration `koanf:"idle_timeout"        default:"120s"    validate:"min=0"           desc:"The maximum amount of time to wait for the next request on a keep-alive connection. 0 means read_timeout."`
+	MaxHeaderBytes    int           `koanf:"max_header_bytes"    default:"1048576" validate:"min=0"           desc:"The maximum size of the request headers. 0 means the net/http default (1MB)."`
+	MaxConnections    int           `koanf:"max_connections"     default:"100"     validate:"min=0"           desc:"The maximum number of simultaneous connections; further connections wait to be accepted. 0 means no limit."`
+	H2C               bool          `koanf:"h2c"                 default:"false"                              desc:"Serves HTTP/2 cleartext (prior knowledge) along with HTTP/1.1 when tls is disabled."`
+	Socket            SocketConfig  `koanf:"socket"`
+}
+
+// AdminConfig is the config of the admin server.
+type AdminConfig struct {
+	Enabled bool `koanf:"enabled" default:"true" desc:"Serves the admin server."`
+	ListenerConfig
+}
+
+// ConfigDefaults returns the defaults of the admin server; its write timeout bounds e.g. the pprof profile duration.
+func (AdminConfig) ConfigDefaults() map[string]string {
+	return map[string]string{"ip": "127.0.0.1", "port": "8889", "write_timeout": "60s"}
+}
+
+// MetricsConfig is the config of the metrics server.
+type MetricsConfig struct {
+	Enabled bool `koanf:"enabled" default:"false" desc:"Serves the metrics server."`
+	ListenerConfig
+}
+
+// ConfigDefaults returns the defaults of the metrics server.
+func (MetricsConfig) ConfigDefaults() map[string]string {
+	return map[string]string{"ip": "127.0.0.1", "port": "9090", "write_timeout": "60s"}
+}
+
+func (c ListenerConfig) Address() Address {
+	return NewAddress(c.IP, c.Port, c.Socket)
+}
+
+// ServerOptions returns the options of the listener's server.
+func (c ListenerConfig) ServerOptions() ServerOptions {
+	return ServerOptions{
+		ReadHeaderTimeout: c.ReadHeaderTimeout,
+		ReadTimeout:       c.ReadTimeout,
+		WriteTimeout:      c.WriteTimeout,
+		IdleTimeout:       c.IdleTimeout,
+		MaxHeaderBytes:    c.MaxHeaderBytes,
+		MaxConnections:    c.MaxConnections,
+		H2C:               c.H2C,
+	}
+}
+
+type namedServer struct {
+	name     string
+	shutdown func(context.Context) error
+}
+
+// Servers is a set of named http servers that are started and shut down together.
+type Servers struct {
+	mu      sync.Mutex
+	servers []namedServer
+}
+
+// Start starts serving handler over ln (see StartListenAndServe) as the server name.
+func (s *Servers) Start(ctx context.Context, name string, ln net.Listener, handler http.Handler, opts ServerOptions, fatalErrCh chan<- error) {
+	server := StartListenAndServe(ln, handler, opts, fatalErrCh)
+
+	s.add(name, server.Shutdown)
+
+	zlog.GetFromContext(ctx).InfoContext(ctx, "http server started", slog.String("name", name), slog.String("bind", ln.Addr().String()), slog.Bool("tls", opts.TLSConfig != nil), slog.Bool("h2c", opts.H2C && opts.TLSConfig == nil))
+}
+
+// StartHTTP3 starts serving handler over HTTP/3 on conn (see StartHTTP3) as the server name.
+func (s *Servers) StartHTTP3(ctx context.Context, name string, conn net.PacketConn, handler http.Handler, opts ServerOptions, fatalErrCh chan<- error) (*http3.Server, error) {
+	server, err := StartHTTP3(conn, handler, opts, fatalErrCh)
+	if err != nil {
+		return nil, err
+	}
+
+	s.add(name, server.Shutdown)
+
+	zlog.GetFromContext(ctx).InfoContext(ctx, "http3 server started", slog.String("name", name), slog.String("bind", conn.LocalAddr().String()))
+
+	return server, nil
+}
+
+func (s *Servers) add(name string, shutdown func(context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.servers = append(s.servers, namedServer{name: name, shutdown: shutdown})
+}
+
+// Shutdown gracefully shuts down every server concurrently and waits for all of them (or ctx).
+func (s *Servers) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	servers := s.servers
+	s.mu.Unlock()
+
+	errs := make([]error, len(servers))
+	wg := sync.WaitGroup{}
+	for i, ns := range servers {
+		wg.Go(func() {
+			if err := ns.shutdown(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s http server: %w", ns.name, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,122 @@
+package zhttp
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutersSeparation(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(zlog.NOOPLogHandler{})
+	c := Config{DebugEndpoints: true}
+
+	routers := map[string]http.Handler{
+		ListenerPublic:  NewDefaultRouter(t.Context(), c, Authenticators{}, nil, logger),
+		ListenerAdmin:   NewAdminRouter(t.Context(), c, nil),
+		ListenerMetrics: NewMetricsRouter(t.Context()),
+	}
+
+	tests := map[string]map[string]int{
+		"/ping":         {ListenerPublic: http.StatusOK, ListenerAdmin: http.StatusOK, ListenerMetrics: http.StatusOK},
+		"/about":        {ListenerPublic: http.StatusNotFound, ListenerAdmin: http.StatusOK, ListenerMetrics: http.StatusNotFound},
+		"/debug/pprof/": {ListenerPublic: http.StatusNotFound, ListenerAdmin: http.StatusOK, ListenerMetrics: http.StatusNotFound},
+		"/metrics":      {ListenerPublic: http.StatusNotFound, ListenerAdmin: http.StatusNotFound, ListenerMetrics: http.StatusOK},
+		"/echo":         {ListenerPublic: http.StatusUnauthorized, ListenerAdmin: http.StatusNotFound, ListenerMetrics: http.StatusNotFound},
+	}
+
+	for path, expected := range tests {
+		for name, status := range expected {
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, path, nil)
+			routers[name].ServeHTTP(resp, req)
+			assert.Equal(t, status, resp.Code, "%s %s", name, path)
+		}
+	}
+
+	// debug endpoints are opt in.
+	resp := httptest.NewRecorder()
+	NewAdminRouter(t.Context(), Config{}, nil).ServeHTTP(resp, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestServers(t *testing.T) {
+	t.Parallel()
+
+	servers := &Servers{}
+	fatalErrCh := make(chan error, 2)
+	addrs := map[string]string{}
+
+	for _, name := range []string{ListenerPublic, ListenerAdmin} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addrs[name] = ln.Addr().String()
+
+		servers.Start(t.Context(), name, ln, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(name))
+		}), ServerOptions{ReadHeaderTimeout: time.Second}, fatalErrCh)
+	}
+
+	for name, addr := range addrs {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://"+addr, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, name)
+	}
+
+	require.NoError(t, servers.Shutdown(t.Context()))
+
+	// every server is closed.
+	for _, addr := range addrs {
+		_, err := net.DialTimeout("tcp", addr, time.Second)
+		require.Error(t, err)
+	}
+	assert.Empty(t, fatalErrCh)
+}
+
+func TestH2C(t *testing.T) {
+	t.Parallel()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	})
+
+	for name, h2c := range map[string]bool{"enabled": true, "disabled": false} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			server := StartListenAndServe(ln, handler, ServerOptions{ReadHeaderTimeout: time.Second, H2C: h2c}, make(chan error, 1))
+			t.Cleanup(func() { _ = server.Close() })
+
+			// HTTP/1.1 is always served.
+			body, _ := requestBody(t, http.DefaultClient, "http://"+ln.Addr().String())
+			assert.Equal(t, "HTTP/1.1", body)
+
+			protocols := new(http.Protocols)
+			protocols.SetUnencryptedHTTP2(true)
+			client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://"+ln.Addr().String(), nil)
+			require.NoError(t, err)
+			resp, err := client.Do(req)
+			if !h2c {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			assert.Equal(t, 2, resp.ProtoMajor)
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package zhttp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unixScheme prefixes the unix socket addresses: unix:///run/app.sock, or unix://@app for a linux abstract socket.
+const unixScheme = "unix://"
+
+type SocketConfig struct {
+	Mode  string `koanf:"mode"  default:"0660" desc:"The file mode (octal) of the unix socket file."`
+	User  string `koanf:"user"                 desc:"The owner (name or uid) of the unix socket file. Empty keeps the process user."`
+	Group string `koanf:"group"                desc:"The group (name or gid) of the unix socket file. Empty keeps the process group."`
+}
+
+// Address is the network address a server listens to.
+type Address struct {
+	Network string // tcp or unix
+	Addr    string // host:port, a socket file path or @name for an abstract socket.
+	Socket  SocketConfig
+}
+
+// NewAddress returns the address of ip and port. ip can also be a unix socket address (unix:///path.sock or unix://@name),
+// in which case port is ignored.
+func NewAddress(ip string, port int64, sc SocketConfig) Address {
+	if path, isUnix := strings.CutPrefix(ip, unixScheme); isUnix {
+		return Address{Network: "unix", Addr: path, Socket: sc}
+	}
+
+	return Address{Network: "tcp", Addr: net.JoinHostPort(ip, strconv.FormatInt(port, 10))}
+}
+
+func (a Address) String() string {
+	if a.Network == "unix" {
+		return unixScheme + a.Addr
+	}
+
+	return a.Addr
+}
+
+func (a Address) isAbstract() bool {
+	return a.Network == "unix" && strings.HasPrefix(a.Addr, "@")
+}
+
+// listen opens a new listener on a. Unix socket files get the configured mode and ownership,
+// and are removed when the listener is closed.
+func listen(a Address) (net.Listener, error) {
+	if a.Network != "unix" {
+		return net.Listen(a.Network, a.Addr)
+	}
+
+	if a.isAbstract() {
+		if runtime.GOOS != "linux" {
+			return nil, fmt.Errorf("abstract unix sockets are not supported on %s", runtime.GOOS)
+		}
+
+		return net.Listen("unix", a.Addr)
+	}
+
+	if err := removeStaleSocket(a.Addr); err != nil {
+		return nil, err
+	}
+
+	ln, err := listenUnix(a.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applySocketConfig(a.Addr, a.Socket); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("unix socket %s: %w", a.Addr, err)
+	}
+
+	return ln, nil
+}
+
+// removeStaleSocket removes the socket file left behind by a process that did not shut down cleanly.
+// Anything that is not a socket, or a socket that still accepts connections, is left untouched.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if fi.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a unix socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, 100*time.Millisecond)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("unix socket %s is in use", path)
+	}
+
+	return os.Remove(path)
+}
+
+func applySocketConfig(path string, sc SocketConfig) error {
+	if sc.Mode != "" {
+		mode, err := strconv.ParseUint(sc.Mode, 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid mode %q", sc.Mode)
+		}
+		if err := os.Chmod(path, fs.FileMode(mode)); err != nil {
+			return err
+		}
+	}
+
+	if sc.User == "" && sc.Group == "" {
+		return nil
+	}
+
+	uid, gid := -1, -1
+	if sc.User != "" {
+		id, err := lookupID(sc.User, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("user %q: %w", sc.User, err)
+		}
+		uid = id
+	}
+	if sc.Group != "" {
+		id, err := lookupID(sc.Group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("group %q: %w", sc.Group, err)
+		}
+		gid = id
+	}
+
+	return os.Lchown(path, uid, gid)
+}
+
+// lookupID returns the numeric id of nameOrID, resolving names with lookup.
+func lookupID(nameOrID string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+
+	s, err := lookup(nameOrID)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(s)
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package zhttp
+
+import "net"
+
+// listenUnix creates the socket file at path; its mode and ownership are applied afterwards.
+func listenUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
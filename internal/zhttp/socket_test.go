@@ -0,0 +1,167 @@
+package zhttp
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// socketDir returns a short temp dir; unix socket paths are limited to ~108 bytes.
+func socketDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "zhttp")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	return dir
+}
+
+func TestNewAddress(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		ip       string
+		port     int64
+		expected Address
+	}{
+		"ipv4":     {ip: "0.0.0.0", port: 8888, expected: Address{Network: "tcp", Addr: "0.0.0.0:8888"}},
+		"ipv6":     {ip: "::1", port: 8888, expected: Address{Network: "tcp", Addr: "[::1]:8888"}},
+		"unix":     {ip: "unix:///run/app.sock", port: 8888, expected: Address{Network: "unix", Addr: "/run/app.sock"}},
+		"abstract": {ip: "unix://@app", expected: Address{Network: "unix", Addr: "@app"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, NewAddress(tc.ip, tc.port, SocketConfig{}))
+		})
+	}
+}
+
+func TestUnixSocketServer(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(socketDir(t), "app.sock")
+	addr := NewAddress("unix://"+path, 0, SocketConfig{Mode: "0600", User: strconv.Itoa(os.Getuid())})
+
+	l := &Listeners{}
+	ln, err := l.Listen(ListenerPublic, addr)
+	require.NoError(t, err)
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o600), fi.Mode().Perm())
+
+	servers := &Servers{}
+	fatalErrCh := make(chan error, 1)
+	servers.Start(t.Context(), ListenerPublic, ln, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}), ServerOptions{ReadHeaderTimeout: time.Second}, fatalErrCh)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://unix/", nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, "ok", string(body))
+
+	// the socket file is removed on shutdown.
+	require.NoError(t, servers.Shutdown(t.Context()))
+	_, err = os.Stat(path)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	assert.Empty(t, fatalErrCh)
+}
+
+func TestUnixSocketMode(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("unix socket file modes are not supported")
+	}
+
+	dir := socketDir(t)
+
+	// the socket file is created accessible by its owner only.
+	ln, err := listenUnix(filepath.Join(dir, "created.sock"))
+	require.NoError(t, err)
+	defer ln.Close()
+	fi, err := os.Stat(filepath.Join(dir, "created.sock"))
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o600), fi.Mode().Perm())
+
+	// and gets the configured mode once listening.
+	ln, err = listen(Address{Network: "unix", Addr: filepath.Join(dir, "mode.sock"), Socket: SocketConfig{Mode: "0660"}})
+	require.NoError(t, err)
+	defer ln.Close()
+	fi, err = os.Stat(filepath.Join(dir, "mode.sock"))
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o660), fi.Mode().Perm())
+}
+
+func TestUnixSocketStaleFile(t *testing.T) {
+	t.Parallel()
+
+	dir := socketDir(t)
+
+	// a socket left behind (nobody listens to it) is replaced.
+	stale := filepath.Join(dir, "stale.sock")
+	ln, err := net.Listen("unix", stale)
+	require.NoError(t, err)
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, ln.Close())
+
+	ln, err = listen(Address{Network: "unix", Addr: stale})
+	require.NoError(t, err)
+
+	// a socket in use is not.
+	_, err = listen(Address{Network: "unix", Addr: stale})
+	require.Error(t, err)
+	require.NoError(t, ln.Close())
+
+	// neither is a regular file.
+	regular := filepath.Join(dir, "regular")
+	require.NoError(t, os.WriteFile(regular, []byte("x"), 0o600))
+	_, err = listen(Address{Network: "unix", Addr: regular})
+	require.Error(t, err)
+
+	// invalid mode.
+	_, err = listen(Address{Network: "unix", Addr: filepath.Join(dir, "mode.sock"), Socket: SocketConfig{Mode: "rw"}})
+	require.Error(t, err)
+}
+
+func TestAbstractSocket(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != "linux" {
+		t.Skip("abstract sockets are linux only")
+	}
+
+	name := "@zhttp-test-" + strconv.Itoa(os.Getpid())
+	ln, err := listen(NewAddress("unix://"+name, 0, SocketConfig{}))
+	require.NoError(t, err)
+	defer ln.Close()
+
+	assert.Equal(t, name, ln.Addr().String())
+
+	conn, err := net.Dial("unix", name)
+	require.NoError(t, err)
+	_ = conn.Close()
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package zhttp
+
+import (
+	"net"
+	"sync"
+	"syscall"
+)
+
+//nolint:gochecknoglobals
+var umaskMu sync.Mutex // the umask is process wide; concurrent listens must not restore each other's.
+
+// listenUnix creates the socket file at path accessible by its owner only, so that it is not reachable through the
+// default umask permissions until the configured mode and ownership are applied.
+func listenUnix(path string) (net.Listener, error) {
+	umaskMu.Lock()
+	defer umaskMu.Unlock()
+
+	old := syscall.Umask(0o177)
+	defer syscall.Umask(old)
+
+	return net.Listen("unix", path)
+}
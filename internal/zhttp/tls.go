@@ -0,0 +1,271 @@
+package zhttp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/moukoublen/goboilerplate/internal/zlog"
+)
+
+type TLSConfig struct {
+	Enabled      bool     `koanf:"enabled"        default:"false"                                 desc:"Serves https instead of plain http."`
+	CertFile     string   `koanf:"cert_file"                                                      desc:"The PEM encoded certificate (chain) file. It is reloaded on change."`
+	KeyFile      string   `koanf:"key_file"                                                       desc:"The PEM encoded private key file. It is reloaded on change."`
+	MinVersion   string   `koanf:"min_version"    default:"1.2"     validate:"oneof=1.0 1.1 1.2 1.3" desc:"The minimum TLS version."`
+	CipherSuites []string `koanf:"cipher_suites"                                                  desc:"The TLS 1.0-1.2 cipher suites (crypto/tls names, e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256). Empty means the Go defaults."`
+	ClientCAFile string   `koanf:"client_ca_file"                                                 desc:"The PEM encoded CA bundle that client certificates are verified against (mTLS). Empty disables client certificates. It is reloaded on change."`
+	ClientAuth   string   `koanf:"client_auth"    default:"require" validate:"oneof=require verify_if_given" desc:"When client_ca_file is set: require a verified client certificate, or verify it only if given."`
+}
+
+// tlsReloadDebounce groups the bursts of fs events that a single certificate rotation usually produces.
+const tlsReloadDebounce = 200 * time.Millisecond
+
+//nolint:gochecknoglobals
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewTLSConfig builds the server *tls.Config. Certificate, key and client CA files are watched, and reloaded on change,
+// until ctx is done. A failed reload is logged and the previous certificates stay active.
+func NewTLSConfig(ctx context.Context, c TLSConfig) (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("http.tls.cert_file and http.tls.key_file are required")
+	}
+
+	minVersion, found := tlsVersions[c.MinVersion]
+	if !found {
+		return nil, fmt.Errorf("unsupported tls version %q", c.MinVersion)
+	}
+
+	suites, err := cipherSuites(c.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &tlsReloader{c: c}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	base := &tls.Config{
+		// set upfront since every handshake of mTLS gets a clone of base (see GetConfigForClient).
+		NextProtos:     []string{"h2", "http/1.1"},
+		MinVersion:     minVersion,
+		CipherSuites:   suites,
+		GetCertificate: r.GetCertificate,
+	}
+
+	if c.ClientCAFile != "" {
+		base.ClientAuth = tls.RequireAndVerifyClientCert
+		if c.ClientAuth == "verify_if_given" {
+			base.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+
+		// the client CA pool can not be swapped in place; every handshake gets a config with the current pool.
+		base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cfg := base.Clone()
+			cfg.GetConfigForClient = nil
+			cfg.ClientCAs = r.clientCAs.Load()
+			return cfg, nil
+		}
+	}
+
+	if err := r.watch(ctx); err != nil {
+		return nil, err
+	}
+
+	return base, nil
+}
+
+func cipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	available := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		available[s.Name] = s.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, n := range names {
+		id, found := available[n]
+		if !found {
+			return nil, fmt.Errorf("unsupported or insecure cipher suite %q", n)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// tlsReloader holds the current certificate and client CA pool.
+type tlsReloader struct {
+	c         TLSConfig
+	cert      atomic.Pointer[tls.Certificate]
+	clientCAs atomic.Pointer[x509.CertPool]
+}
+
+func (r *tlsReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+func (r *tlsReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.c.CertFile, r.c.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tls certificate: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if r.c.ClientCAFile != "" {
+		b, err := os.ReadFile(r.c.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("tls client ca: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return fmt.Errorf("tls client ca: no certificates found in %s", r.c.ClientCAFile)
+		}
+	}
+
+	r.cert.Store(&cert)
+	if pool != nil {
+		r.clientCAs.Store(pool)
+	}
+
+	return nil
+}
+
+func (r *tlsReloader) watch(ctx context.Context) error {
+	files := map[string]struct{}{}
+	for _, f := range []string{r.c.CertFile, r.c.KeyFile, r.c.ClientCAFile} {
+		if f == "" {
+			continue
+		}
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return err
+		}
+		files[abs] = struct{}{}
+	}
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// parent directories are watched so that atomic replacements (rename, symlink swap) are picked up.
+	for f := range files {
+		if err := fw.Add(filepath.Dir(f)); err != nil {
+			_ = fw.Close()
+			return err
+		}
+	}
+
+	go func() {
+		logger := zlog.GetFromContext(ctx)
+		defer func() { _ = fw.Close() }()
+
+		var debounce *time.Timer
+		reload := func() {
+			if err := r.reload(); err != nil {
+				logger.ErrorContext(ctx, "tls certificates reload failed, keeping the previous ones", zlog.Error(err))
+				return
+			}
+			logger.InfoContext(ctx, "tls certificates reloaded")
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				if debounce != nil {
+					debounce.Stop()
+				}
+				return
+
+			case event, ok := <-fw.Events:
+				if !ok {
+					return
+				}
+				// k8s secrets are updated by swapping the ..data symlink, so any event in the directory counts.
+				if _, isWatched := files[filepath.Clean(event.Name)]; !isWatched && filepath.Base(event.Name) != "..data" {
+					continue
+				}
+				if debounce == nil {
+					debounce = time.AfterFunc(tlsReloadDebounce, reload)
+				} else {
+					debounce.Reset(tlsReloadDebounce)
+				}
+
+			case err, ok := <-fw.Errors:
+				if !ok {
+					return
+				}
+				logger.WarnContext(ctx, "tls files watcher error", zlog.Error(err))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// ClientIdentity is the identity of a verified client certificate (mTLS).
+type ClientIdentity struct {
+	CommonName     string
+	Organization   []string
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []string // e.g. spiffe://cluster.local/ns/default/sa/client
+	SerialNumber   string
+	Issuer         string
+}
+
+type ctxClientIdentityKey struct{}
+
+// ClientIdentityFromContext returns the identity of the verified client certificate of the request, if any.
+func ClientIdentityFromContext(ctx context.Context) (ClientIdentity, bool) {
+	id, found := ctx.Value(ctxClientIdentityKey{}).(ClientIdentity)
+	return id, found
+}
+
+// ClientIdentityMiddleware places the identity of the verified client certificate (if any) in the request context.
+// Only verified chains are taken into account; unverified certificates are ignored.
+func ClientIdentityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cert := r.TLS.VerifiedChains[0][0]
+		id := ClientIdentity{
+			CommonName:     cert.Subject.CommonName,
+			Organization:   cert.Subject.Organization,
+			DNSNames:       cert.DNSNames,
+			EmailAddresses: cert.EmailAddresses,
+			SerialNumber:   cert.SerialNumber.String(),
+			Issuer:         cert.Issuer.String(),
+		}
+		for _, u := range cert.URIs {
+			id.URIs = append(id.URIs, u.String())
+		}
+
+		ctx := context.WithValue(r.Context(), ctxClientIdentityKey{}, id)
+		zlog.GetFromContext(ctx).DebugContext(ctx, "client certificate verified", slog.String("cn", id.CommonName))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
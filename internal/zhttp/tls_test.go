@@ -0,0 +1,197 @@
+package zhttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert creates a certificate signed by parent (self signed when parent is nil).
+func newTestCert(t *testing.T, serial int64, cn string, parent *testCert, isCA bool) testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: cn, Organization: []string{"test"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if isCA {
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	}
+
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeTestCert(t *testing.T, dir string, c testCert) (string, string) {
+	t.Helper()
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(keyFile, c.keyPEM, 0o600))
+	require.NoError(t, os.WriteFile(certFile, c.certPEM, 0o600))
+
+	return certFile, keyFile
+}
+
+func TestNewTLSConfigErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, newTestCert(t, 1, "server", nil, false))
+
+	tests := map[string]TLSConfig{
+		"missing files":         {MinVersion: "1.2"},
+		"unknown version":       {CertFile: certFile, KeyFile: keyFile, MinVersion: "2.0"},
+		"insecure cipher suite": {CertFile: certFile, KeyFile: keyFile, MinVersion: "1.2", CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+		"missing cert file":     {CertFile: filepath.Join(dir, "nope.crt"), KeyFile: keyFile, MinVersion: "1.2"},
+		"invalid client ca":     {CertFile: certFile, KeyFile: keyFile, MinVersion: "1.2", ClientCAFile: keyFile},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewTLSConfig(t.Context(), tc)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestTLSMutualAuthAndReload(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ca := newTestCert(t, 1, "test ca", nil, true)
+	certFile, keyFile := writeTestCert(t, dir, newTestCert(t, 10, "server", &ca, false))
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, ca.certPEM, 0o600))
+
+	tlsConf, err := NewTLSConfig(t.Context(), TLSConfig{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		MinVersion:   "1.2",
+		ClientCAFile: caFile,
+		ClientAuth:   "require",
+	})
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	handler := ClientIdentityMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, found := ClientIdentityFromContext(r.Context())
+		if !found {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.WriteString(w, id.CommonName)
+	}))
+
+	fatalErrCh := make(chan error, 1)
+	server := StartListenAndServe(ln, handler, ServerOptions{ReadHeaderTimeout: time.Second, TLSConfig: tlsConf}, fatalErrCh)
+	t.Cleanup(func() { _ = server.Close() })
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	url := "https://" + ln.Addr().String()
+
+	// without a client certificate the handshake is rejected.
+	_, err = get(t, roots, nil, url)
+	require.Error(t, err)
+
+	clientCert := newTestCert(t, 20, "client-a", &ca, false)
+	pair, err := tls.X509KeyPair(clientCert.certPEM, clientCert.keyPEM)
+	require.NoError(t, err)
+
+	resp, err := get(t, roots, &pair, url)
+	require.NoError(t, err)
+	assert.Equal(t, "client-a", resp.body)
+	assert.Equal(t, int64(10), resp.serverSerial)
+
+	// rotate the server certificate on disk.
+	writeTestCert(t, dir, newTestCert(t, 11, "server", &ca, false))
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		resp, err := get(t, roots, &pair, url)
+		if assert.NoError(c, err) {
+			assert.Equal(c, int64(11), resp.serverSerial)
+		}
+	}, 5*time.Second, 50*time.Millisecond)
+
+	assert.Empty(t, fatalErrCh)
+}
+
+type testTLSResponse struct {
+	body         string
+	serverSerial int64
+}
+
+// get performs a request over a new connection, so every call goes through a new handshake.
+func get(t *testing.T, roots *x509.CertPool, cert *tls.Certificate, url string) (testTLSResponse, error) {
+	t.Helper()
+
+	clientConf := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	if cert != nil {
+		clientConf.Certificates = []tls.Certificate{*cert}
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConf, DisableKeepAlives: true}}
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	if err != nil {
+		return testTLSResponse{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return testTLSResponse{}, err
+	}
+
+	return testTLSResponse{body: string(b), serverSerial: resp.TLS.PeerCertificates[0].SerialNumber.Int64()}, nil
+}
@@ -4,8 +4,6 @@ import (
 	"context"
 	"log/slog"
 	"os"
-
-	"github.com/knadh/koanf/v2"
 )
 
 type LogType string
@@ -16,27 +14,8 @@ const (
 )
 
 type Config struct {
-	LogType LogType
-	Level   slog.Level
-}
-
-func ParseConfig(cnf *koanf.Koanf) Config {
-	level := slog.Level(0)
-	levelStr := cnf.Bytes("log.level")
-	err := level.UnmarshalText(levelStr)
-	_ = err
-
-	return Config{
-		Level:   level,
-		LogType: LogType(cnf.String("log.type")),
-	}
-}
-
-func DefaultConfigValues() map[string]any {
-	return map[string]any{
-		"log.type":  "text",
-		"log.level": "INFO",
-	}
+	LogType LogType    `koanf:"type"  default:"text" validate:"oneof=json text" desc:"The log output format."`
+	Level   slog.Level `koanf:"level" default:"INFO"                            desc:"The minimum log level (DEBUG, INFO, WARN, ERROR). It is applied live on config reload."`
 }
 
 //nolint:gochecknoglobals
@@ -51,8 +30,9 @@ func InitSLog(c Config, attrs ...slog.Attr) *slog.Logger {
 	SetLogLevel(c.Level)
 
 	opts := &slog.HandlerOptions{
-		AddSource: true,
-		Level:     logLevel,
+		AddSource:   true,
+		Level:       logLevel,
+		ReplaceAttr: ReplaceAttr,
 	}
 
 	var slogHandler slog.Handler
@@ -86,6 +66,35 @@ func SetInContext(ctx context.Context, logger *slog.Logger) context.Context {
 	return context.WithValue(ctx, ctxSLogKey{}, logger)
 }
 
+// AuditKey is the attribute (audit=true) of the audit log records, e.g. the authorization decisions, so they can
+// be told apart (and routed or retained separately) from the rest of the logs.
+const AuditKey = "audit"
+
+// AuditLevel is the minimum level of the audit log records, whatever the log level.
+const AuditLevel = slog.LevelInfo
+
+// Audit returns the logger of ctx for audit log records. It writes through the handler of the logger of ctx, with
+// its attributes, but its records of AuditLevel and above are never dropped by the log level.
+func Audit(ctx context.Context) *slog.Logger {
+	return slog.New(auditHandler{GetFromContext(ctx).Handler()}).With(slog.Bool(AuditKey, true))
+}
+
+// auditHandler is a slog.Handler that enables the records of AuditLevel and above, regardless of the level of the
+// wrapped handler.
+type auditHandler struct {
+	slog.Handler
+}
+
+func (auditHandler) Enabled(_ context.Context, l slog.Level) bool { return l >= AuditLevel }
+
+func (h auditHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return auditHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h auditHandler) WithGroup(name string) slog.Handler {
+	return auditHandler{h.Handler.WithGroup(name)}
+}
+
 type NOOPLogHandler struct{}
 
 func (NOOPLogHandler) Enabled(context.Context, slog.Level) bool  { return false }
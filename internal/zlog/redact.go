@@ -0,0 +1,83 @@
+package zlog
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Redacted replaces the redacted values in the log attributes.
+const Redacted = "[REDACTED]"
+
+//nolint:gochecknoglobals
+var redaction struct {
+	mu       sync.Mutex
+	values   []string
+	replacer atomic.Pointer[strings.Replacer]
+}
+
+// MinRedactedLength is the minimum length of the values of RedactValues. Shorter ones (e.g. "1" or "admin") are
+// not redacted, as they would mangle unrelated log text.
+const MinRedactedLength = 8
+
+// RedactValues registers values (e.g. the resolved config secrets) to be redacted from every log attribute of the
+// loggers of InitSLog (see ReplaceAttr). Values shorter than MinRedactedLength are ignored.
+func RedactValues(values ...string) {
+	redaction.mu.Lock()
+	defer redaction.mu.Unlock()
+
+	added := false
+	for _, v := range values {
+		if len(v) >= MinRedactedLength && !slices.Contains(redaction.values, v) {
+			redaction.values = append(redaction.values, v)
+			added = true
+		}
+	}
+	if !added {
+		return
+	}
+
+	// the longest first, so that a value that contains another one is redacted whole.
+	slices.SortFunc(redaction.values, func(a, b string) int { return len(b) - len(a) })
+	oldnew := make([]string, 0, len(redaction.values)*2)
+	for _, v := range redaction.values {
+		oldnew = append(oldnew, v, Redacted)
+	}
+	redaction.replacer.Store(strings.NewReplacer(oldnew...))
+}
+
+// ReplaceAttr is the slog.HandlerOptions.ReplaceAttr that redacts the values of RedactValues. Strings are redacted
+// in place; errors and fmt.Stringers that render one of them are replaced whole. Other values (e.g. structs) are
+// not inspected, so as not to format every attribute of every record.
+func ReplaceAttr(_ []string, a slog.Attr) slog.Attr {
+	r := redaction.replacer.Load()
+	if r == nil {
+		return a
+	}
+
+	var s string
+	switch a.Value.Kind() { //nolint:exhaustive
+	case slog.KindString:
+		s = a.Value.String()
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			s = v.Error()
+		case fmt.Stringer:
+			s = v.String()
+		default:
+			return a
+		}
+	default:
+		return a
+	}
+
+	if redacted := r.Replace(s); redacted != s {
+		a.Value = slog.StringValue(redacted)
+	}
+
+	return a
+}
@@ -63,3 +63,13 @@ bench: # runs all benchmarks
 .PHONY: run
 run:
 	go run ./cmd/goboilerplate
+
+.PHONY: config-docs
+config-docs: # generates the config reference and the sample config file
+	{ echo -e "# Configuration\n\nGenerated by \`make config-docs\`.\n"; go run ./cmd/goboilerplate config docs --format markdown; } > docs/config.md
+	go run ./cmd/goboilerplate config docs --format json-schema > docs/config.schema.json
+	go run ./cmd/goboilerplate config docs --format yaml > docs/config.sample.yaml
+
+.PHONY: openapi
+openapi: # generates the OpenAPI document of the default router
+	go run ./cmd/goboilerplate openapi --output api/openapi.json